@@ -249,6 +249,12 @@ func (p *Process) GetStartTime() time.Time {
 	return p.startTime
 }
 
+// GetRetryTimes returns how many times the process was restarted after
+// exiting too quickly
+func (p *Process) GetRetryTimes() int {
+	return p.retryTimes
+}
+
 func (p *Process) GetStopTime() time.Time {
 	switch p.state {
 	case STARTING:
@@ -296,6 +302,18 @@ func (p *Process) GetPriority() int {
 	return p.config.GetInt("priority", 999)
 }
 
+// GetDependencies returns the names of the programs in the depends_on key,
+// which are started before this one.
+func (p *Process) GetDependencies() []string {
+	deps := make([]string, 0)
+	for _, name := range p.config.GetStringArray("depends_on", ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			deps = append(deps, name)
+		}
+	}
+	return deps
+}
+
 func (p *Process) getNumberProcs() int {
 	return p.config.GetInt("numprocs", 1)
 }
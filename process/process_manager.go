@@ -1,6 +1,7 @@
 package process
 
 import (
+	"sort"
 	"strings"
 	"sync"
 
@@ -22,12 +23,89 @@ func (pm *ProcessManager) CreateProcess(config *ConfigEntry) *Process {
 	return pm.createProgram(config)
 }
 
+// StartAutoStartPrograms starts the autostart programs by priority, each
+// after the programs it depends on.
 func (pm *ProcessManager) StartAutoStartPrograms() {
-	pm.ForEachProcess(func(proc *Process) {
+	pm.lock.Lock()
+	defer pm.lock.Unlock()
+
+	for _, proc := range startOrder(pm.getAllProcess()) {
 		if proc.isAutoStart() {
 			proc.Start(false)
 		}
-	})
+	}
+}
+
+type byPriority []*Process
+
+func (p byPriority) Len() int      { return len(p) }
+func (p byPriority) Swap(i, j int) { p[i], p[j] = p[j], p[i] }
+func (p byPriority) Less(i, j int) bool {
+	if p[i].GetPriority() != p[j].GetPriority() {
+		return p[i].GetPriority() < p[j].GetPriority()
+	}
+	return p[i].GetName() < p[j].GetName()
+}
+
+// startOrder sorts the processes by priority, then moves each process after
+// the processes it depends on. Dependency cycles are broken arbitrarily.
+func startOrder(procs []*Process) []*Process {
+	sort.Sort(byPriority(procs))
+	byName := make(map[string]*Process)
+	for _, proc := range procs {
+		byName[proc.GetName()] = proc
+	}
+
+	ordered := make([]*Process, 0, len(procs))
+	visited := make(map[*Process]bool)
+	var visit func(proc *Process)
+	visit = func(proc *Process) {
+		if visited[proc] {
+			return
+		}
+		visited[proc] = true
+		for _, name := range proc.GetDependencies() {
+			if dep, ok := byName[name]; ok {
+				visit(dep)
+			}
+		}
+		ordered = append(ordered, proc)
+	}
+	for _, proc := range procs {
+		visit(proc)
+	}
+	return ordered
+}
+
+// StartDependencies starts the programs the named program depends on, and
+// the programs they depend on, in start order. Programs already started are
+// left alone.
+func (pm *ProcessManager) StartDependencies(name string) {
+	pm.lock.Lock()
+	defer pm.lock.Unlock()
+
+	proc, ok := pm.procs[name]
+	if !ok {
+		return
+	}
+	needed := make(map[string]bool)
+	var need func(proc *Process)
+	need = func(proc *Process) {
+		for _, dep := range proc.GetDependencies() {
+			if !needed[dep] && dep != name {
+				needed[dep] = true
+				if p, ok := pm.procs[dep]; ok {
+					need(p)
+				}
+			}
+		}
+	}
+	need(proc)
+	for _, proc := range startOrder(pm.getAllProcess()) {
+		if needed[proc.GetName()] {
+			proc.Start(false)
+		}
+	}
 }
 
 func (pm *ProcessManager) createProgram(config *ConfigEntry) *Process {
@@ -0,0 +1,28 @@
+package process
+
+import (
+	"reflect"
+	"testing"
+)
+
+func newTestProcess(name string, keyValues map[string]string) *Process {
+	return NewProcess(&ConfigEntry{Name: name, KeyValues: keyValues})
+}
+
+func TestStartOrder(t *testing.T) {
+	procs := []*Process{
+		newTestProcess("web", map[string]string{"depends_on": "db, cache"}),
+		newTestProcess("cache", map[string]string{"priority": "1"}),
+		newTestProcess("db", map[string]string{"priority": "500"}),
+		newTestProcess("worker", map[string]string{"priority": "1", "depends_on": "db,missing"}),
+		newTestProcess("audit", nil),
+	}
+	var got []string
+	for _, proc := range startOrder(procs) {
+		got = append(got, proc.GetName())
+	}
+	want := []string{"cache", "db", "worker", "audit", "web"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("startOrder: %q, want %q", got, want)
+	}
+}
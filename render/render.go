@@ -0,0 +1,75 @@
+// Copyright 2015 Daniel Theophanes.
+// Use of this source code is governed by a zlib-style
+// license that can be found in the LICENSE file.
+
+// Package render produces the files of every supported service system from a
+// service.Config, regardless of the OS it runs on. This allows a packaging
+// pipeline to generate the init files of all systems from one build host.
+//
+// Only the Config is used; the program is assumed not to implement
+// service.Reloader, so set the ReloadSignal option to render reload support.
+package render // import "github.com/isaaxiot/service/render"
+
+import (
+	"github.com/isaaxiot/service"
+)
+
+// Systems lists the service systems that can be rendered, by the name
+// returned from service.System.String.
+var Systems = []string{
+	"linux-systemd",
+	"unix-systemv",
+	"linux-upstart",
+	"linux-procd",
+	"linux-openrc",
+	"linux-runit",
+	"darwin-launchd",
+}
+
+// Systemd renders the systemd unit of c.
+func Systemd(c *service.Config) (*service.Manifest, error) {
+	return service.RenderSystem("linux-systemd", c)
+}
+
+// SystemV renders the SysV init script of c.
+func SystemV(c *service.Config) (*service.Manifest, error) {
+	return service.RenderSystem("unix-systemv", c)
+}
+
+// Upstart renders the Upstart job of c.
+func Upstart(c *service.Config) (*service.Manifest, error) {
+	return service.RenderSystem("linux-upstart", c)
+}
+
+// Procd renders the OpenWrt procd init script of c.
+func Procd(c *service.Config) (*service.Manifest, error) {
+	return service.RenderSystem("linux-procd", c)
+}
+
+// OpenRC renders the openrc-run script of c.
+func OpenRC(c *service.Config) (*service.Manifest, error) {
+	return service.RenderSystem("linux-openrc", c)
+}
+
+// Runit renders the runit service directory of c.
+func Runit(c *service.Config) (*service.Manifest, error) {
+	return service.RenderSystem("linux-runit", c)
+}
+
+// Launchd renders the launchd property list of c.
+func Launchd(c *service.Config) (*service.Manifest, error) {
+	return service.RenderSystem("darwin-launchd", c)
+}
+
+// All renders c for every system in Systems, keyed by system name.
+func All(c *service.Config) (map[string]*service.Manifest, error) {
+	all := make(map[string]*service.Manifest, len(Systems))
+	for _, name := range Systems {
+		m, err := service.RenderSystem(name, c)
+		if err != nil {
+			return nil, err
+		}
+		all[name] = m
+	}
+	return all, nil
+}
@@ -0,0 +1,88 @@
+// Copyright 2015 Daniel Theophanes.
+// Use of this source code is governed by a zlib-style
+// license that can be found in the LICENSE file.
+
+package render_test
+
+import (
+	"bytes"
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/isaaxiot/service"
+	"github.com/isaaxiot/service/render"
+)
+
+var update = flag.Bool("update", false, "update the golden files in testdata")
+
+func testConfig() *service.Config {
+	return &service.Config{
+		Name:        "example",
+		DisplayName: "Example Service",
+		Description: "An example Go service.",
+		Executable:  "/usr/bin/example",
+		Arguments:   []string{"-config", "/etc/example/config.json"},
+		Envs: map[string]string{
+			"LOG_LEVEL": "debug",
+			"GREETING":  `it's "100%" \o/`,
+		},
+		WorkingDirectory: "/var/lib/example",
+		Option: service.KeyValue{
+			"ReloadSignal": "USR1",
+			"Notify":       true,
+			"WatchdogSec":  30 * time.Second,
+			"ListenStream": []string{"80", "/run/example.sock"},
+		},
+	}
+}
+
+func TestGolden(t *testing.T) {
+	all, err := render.All(testConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range render.Systems {
+		m := all[name]
+		if len(m.Files) == 0 {
+			t.Errorf("%s: no files rendered", name)
+			continue
+		}
+		for i, f := range m.Files {
+			// Additional files, such as a systemd socket unit, are told
+			// apart by their extension, or by their path in the directory
+			// of the first file, such as the log/run script of runit.
+			golden := filepath.Join("testdata", name+".golden")
+			if i > 0 {
+				suffix := filepath.Ext(f.Path)
+				if len(suffix) == 0 {
+					rel, _ := filepath.Rel(filepath.Dir(m.Files[0].Path), f.Path)
+					suffix = "." + strings.Replace(rel, "/", "-", -1)
+				}
+				golden = filepath.Join("testdata", name+suffix+".golden")
+			}
+			if *update {
+				if err := ioutil.WriteFile(golden, f.Content, 0644); err != nil {
+					t.Fatal(err)
+				}
+				continue
+			}
+			want, err := ioutil.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(f.Content, want) {
+				t.Errorf("%s: rendered file differs from %s:\n%s", name, golden, f.Content)
+			}
+		}
+	}
+}
+
+func TestUnknownSystem(t *testing.T) {
+	if _, err := service.RenderSystem("windows-service", testConfig()); err == nil {
+		t.Error("RenderSystem for windows-service should fail")
+	}
+}
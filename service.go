@@ -3,7 +3,7 @@
 // license that can be found in the LICENSE file.
 
 // Package service provides a simple way to create a system service.
-// Currently supports Windows, Linux/(systemd | Upstart | SysV), and OSX/Launchd.
+// Currently supports Windows, Linux/(systemd | Upstart | SysV | OpenRC | runit), and OSX/Launchd.
 //
 // Windows controls services by setting up callbacks that is non-trivial. This
 // is very different then other systems. This package provides the same API
@@ -62,8 +62,12 @@
 package service // import "github.com/isaaxiot/service"
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"strconv"
+	"strings"
+	"time"
 )
 
 const (
@@ -81,6 +85,27 @@ const (
 	optionRunWait      = "RunWait"
 	optionReloadSignal = "ReloadSignal"
 	optionPIDFile      = "PIDFile"
+
+	optionStartTimeout         = "StartTimeout"
+	optionStartTimeoutDefault  = time.Duration(0)
+	optionStopTimeout          = "StopTimeout"
+	optionStopTimeoutDefault   = time.Duration(0)
+	optionExitOnTimeout        = "ExitOnTimeout"
+	optionExitOnTimeoutDefault = false
+
+	optionPassword = "Password"
+
+	optionInstallRoot = "InstallRoot"
+
+	optionNotify        = "Notify"
+	optionNotifyDefault = false
+	optionWatchdogSec   = "WatchdogSec"
+
+	optionLinger        = "Linger"
+	optionLingerDefault = false
+
+	optionListenStream   = "ListenStream"
+	optionListenDatagram = "ListenDatagram"
 )
 
 // Config provides the setup for a Service. The Name field is required.
@@ -96,10 +121,39 @@ type Config struct {
 	// If empty the current executable is used.
 	Executable string
 
-	// Array of service dependencies.
-	// Not yet implemented on Linux or OS X.
+	// Array of service dependencies. Each entry is one or more service
+	// names separated by spaces, optionally prefixed to tell how strong
+	// the dependency is:
+	//  * Requires= (default) - Start after them, do not start without them.
+	//  * Wants=              - Start after them if they are available.
+	//  * After=              - Only start after them.
+	// Names may be systemd units such as network-online.target or LSB
+	// facilities such as $network.
+	//
+	// Windows only uses Requires entries, each naming one service since
+	// Windows service names may contain spaces. Upstart and runit only use
+	// the services of Requires entries. Wants= and After= entries are
+	// ignored on these systems, and all entries are ignored on OS X.
+	// Supervised services start the supervised services they depend on
+	// first.
 	Dependencies []string
 
+	// When the OS service manager restarts the service.
+	// Not supported on Windows.
+	RestartPolicy RestartPolicy
+
+	// Optional schedule of a periodic task, which then runs to completion
+	// on the schedule instead of always. Either an interval in Go duration
+	// syntax such as "15m", or a systemd calendar expression such as
+	// "daily" or "Mon..Fri *-*-* 02:30". On systemd a .timer unit starts
+	// a oneshot service, elsewhere on Linux an /etc/cron.d entry runs the
+	// executable, which only supports intervals dividing an hour or a day
+	// and expressions that cron can express. Run calls Start and then Stop
+	// without waiting, so the program does its work in Start, which is not
+	// limited by the StartTimeout option unless the option is set.
+	// Not supported on OS X, Windows, OpenRC, runit and procd.
+	Schedule string
+
 	// The following fields are not supported on Windows.
 	WorkingDirectory string // Initial working directory.
 	ChRoot           string
@@ -113,8 +167,88 @@ type Config struct {
 	//  * POSIX
 	//    - RunWait      func() (wait for SIGNAL) - Do not install signal but wait for this function to return.
 	//    - ReloadSignal string () [USR1, ...] - Signal to send on reaload.
+	//                   Defaults to HUP if the program implements Reloader.
 	//    - PIDFile     string () [/run/prog.pid] - Location of the PID file.
+	//    - InstallRoot string () [/tmp/pkgroot] - Install the files under this root directory
+	//                  and do not run any command, for packaging. See InstallManifest.
+	//  * Linux (systemd)
+	//    - UserService bool (false) - Install as a user service of the current user.
+	//    - Linger      bool (false) - Start the user services of the current user at boot.
+	//    - Notify      bool (false) - Use Type=notify, Run reports readiness to systemd. See Notifier.
+	//    - WatchdogSec time.Duration () - Restart the service if Run does not ping systemd within
+	//                  this time. Pings stop while the program fails its HealthChecker.
+	//    - ListenStream   []string () [:80, /run/prog.sock] - Stream sockets of a .socket unit
+	//                     installed with the service. See Listeners.
+	//    - ListenDatagram []string () [:53] - Datagram sockets of the .socket unit.
+	//  * Linux (runit)
+	//    - StandardOutPath string () [/var/log/prog] - Directory svlogd writes the output to.
+	//  * Windows
+	//    - Password     string () - Password of the UserName account.
+	//  * All
+	//    - StartTimeout  time.Duration (0) - Time allowed for Start to return, unlimited if 0.
+	//    - StopTimeout   time.Duration (0) - Time allowed for Stop to return, unlimited if 0.
+	//    - ExitOnTimeout bool (false) - Exit the process with status 1 if Start or Stop time out.
 	Option KeyValue
+
+	// Commander runs the commands of the service system, such as systemctl.
+	// If nil the commands are run on the host. Not used on Windows.
+	Commander Commander
+}
+
+// RestartMode tells when a service that exited is restarted.
+type RestartMode string
+
+// Restart modes.
+const (
+	RestartAlways    RestartMode = "always"     // Restart whatever the exit status.
+	RestartOnFailure RestartMode = "on-failure" // Restart unless the exit status is a success.
+	RestartNever     RestartMode = "never"      // Do not restart.
+)
+
+// RestartPolicy describes when and how quickly the OS service manager
+// restarts the service. Zero fields keep the default of the service system.
+//
+// Procd and OpenRC do not tell success from failure, they restart on-failure
+// services like always ones. Upstart waits the Delay after every stop. Burst,
+// Window and SuccessExitCodes are not supported by launchd, Burst and Window
+// by runit. Supervised services reject Delay and Window, Burst limits the
+// failed starts in a row there.
+type RestartPolicy struct {
+	Mode RestartMode
+
+	// Delay is the time to wait before restarting.
+	Delay time.Duration
+
+	// The service is not restarted again once it restarted Burst times
+	// within Window.
+	Burst  int
+	Window time.Duration
+
+	// SuccessExitCodes are the exit statuses besides 0 that are a success
+	// for RestartOnFailure.
+	SuccessExitCodes []int
+}
+
+// restartPolicyOr returns the RestartPolicy with the zero fields taken from
+// def, the default of the service system.
+func (c *Config) restartPolicyOr(def RestartPolicy) RestartPolicy {
+	p := c.RestartPolicy
+	if len(p.Mode) == 0 {
+		p.Mode = def.Mode
+	}
+	if p.Delay == 0 {
+		p.Delay = def.Delay
+	}
+	if p.Burst == 0 {
+		p.Burst = def.Burst
+	}
+	if p.Window == 0 {
+		p.Window = def.Window
+	}
+	if len(p.SuccessExitCodes) == 0 {
+		p.SuccessExitCodes = def.SuccessExitCodes
+	}
+	return p
 }
 
 var (
@@ -131,6 +265,15 @@ var (
 	ErrServiceIsNotInstalled = errors.New("Service is not installed.")
 	// ErrServiceIsNotRunning is returned when the service is not running
 	ErrServiceIsNotRunning = errors.New("Service is not running.")
+	// ErrStartTimeout is returned by Run when Interface.Start does not return
+	// within the StartTimeout option.
+	ErrStartTimeout = errors.New("Interface.Start did not return before the start timeout.")
+	// ErrStopTimeout is returned by Run when Interface.Stop does not return
+	// within the StopTimeout option.
+	ErrStopTimeout = errors.New("Interface.Stop did not return before the stop timeout.")
+	// ErrScheduled is returned when starting or stopping a service that
+	// cron runs on its Schedule.
+	ErrScheduled = errors.New("Service is started by cron on its schedule.")
 )
 
 // New creates a new service based on a service interface and configuration.
@@ -181,6 +324,17 @@ func (kv KeyValue) string(name string, defaultValue string) string {
 	return defaultValue
 }
 
+// strings returns the value of the given name, assuming the value is a []string.
+// If the value isn't found or is not of the type, the defaultValue is returned.
+func (kv KeyValue) strings(name string, defaultValue []string) []string {
+	if v, found := kv[name]; found {
+		if castValue, is := v.([]string); is {
+			return castValue
+		}
+	}
+	return defaultValue
+}
+
 // float64 returns the value of the given name, assuming the value is a float64.
 // If the value isn't found or is not of the type, the defaultValue is returned.
 func (kv KeyValue) float64(name string, defaultValue float64) float64 {
@@ -192,6 +346,17 @@ func (kv KeyValue) float64(name string, defaultValue float64) float64 {
 	return defaultValue
 }
 
+// duration returns the value of the given name, assuming the value is a time.Duration.
+// If the value isn't found or is not of the type, the defaultValue is returned.
+func (kv KeyValue) duration(name string, defaultValue time.Duration) time.Duration {
+	if v, found := kv[name]; found {
+		if castValue, is := v.(time.Duration); is {
+			return castValue
+		}
+	}
+	return defaultValue
+}
+
 // funcSingle returns the value of the given name, assuming the value is a float64.
 // If the value isn't found or is not of the type, the defaultValue is returned.
 func (kv KeyValue) funcSingle(name string, defaultValue func()) func() {
@@ -238,6 +403,14 @@ func ChooseSystem(a ...System) {
 	system = newSystem()
 }
 
+// OverrideSystem is like ChooseSystem, but the given system services are
+// considered before those already suggested, so a program or test can
+// replace the detected system, see package servicetest.
+func OverrideSystem(a ...System) {
+	systemRegistry = append(append([]System{}, a...), systemRegistry...)
+	system = newSystem()
+}
+
 // ChosenSystem returns the system that service will use.
 func ChosenSystem() System {
 	return system
@@ -249,6 +422,17 @@ func AvailableSystems() []System {
 	return systemRegistry
 }
 
+// Commander runs the external commands of a service system. Replace it to
+// test services without the service system, see package servicetest.
+type Commander interface {
+	// Run runs the command and returns an error if it fails.
+	Run(name string, args ...string) error
+	// Output runs the command and returns its combined output. If the
+	// command runs but exits with a non-zero status, the error should have
+	// an ExitCode() int method like *exec.ExitError.
+	Output(name string, args ...string) ([]byte, error)
+}
+
 // System represents the service manager that is available.
 type System interface {
 	// String returns a description of the system.
@@ -290,6 +474,75 @@ type Interface interface {
 	Stop(s Service) error
 }
 
+// ContextInterface may be implemented in addition to Interface. If the program
+// passed to New implements it, Run calls StartContext and StopContext instead
+// of Start and Stop. If the StartTimeout or StopTimeout option is set, the
+// context is cancelled once it elapses; the function should return promptly
+// after that. Without the options the context is never cancelled.
+//
+// Run returns ErrStartTimeout or ErrStopTimeout if the program overruns a
+// set timeout, whether or not it implements ContextInterface.
+type ContextInterface interface {
+	Interface
+
+	// StartContext is called instead of Interface.Start.
+	StartContext(ctx context.Context, s Service) error
+
+	// StopContext is called instead of Interface.Stop.
+	StopContext(ctx context.Context, s Service) error
+}
+
+// Reloader may be implemented in addition to Interface. If the program passed
+// to New implements it, Run calls Reload when the OS service manager asks the
+// service to reload instead of stopping the program. On POSIX systems this is
+// the ReloadSignal option, on Windows the ParamChange control.
+type Reloader interface {
+	// Reload provides a place to re-read configuration while running.
+	// Like Start it should return quickly.
+	Reload(s Service) error
+}
+
+// Notifier is implemented by services that can report their state to the OS
+// service manager while running. The Service passed to the program can be
+// asserted to a Notifier; systemd services implement it. The methods do
+// nothing when the program does not run under the service manager.
+//
+// Run sends "READY=1" once Interface.Start returns and "STOPPING=1" before
+// calling Interface.Stop. Set the Notify option for systemd to wait for
+// READY before starting dependent services.
+type Notifier interface {
+	// Notify sends a raw state assignment such as "READY=1".
+	Notify(state string) error
+
+	// SetStatus sets the free-form status text of the service.
+	SetStatus(status string) error
+
+	// ExtendTimeout asks for d more time to start or stop before the
+	// service manager gives up on the service.
+	ExtendTimeout(d time.Duration) error
+}
+
+// HealthChecker may be implemented in addition to Interface. If the service
+// manager watches the service, as systemd does with the WatchdogSec option,
+// Run keeps it from restarting the service only while CheckHealth returns nil.
+type HealthChecker interface {
+	// CheckHealth returns an error if the program no longer works. It is
+	// called at half the watchdog interval and must return quickly.
+	CheckHealth(s Service) error
+}
+
+// reloadSignalName returns the signal sent to the program on reload without
+// the "SIG" prefix, or "" if the program does not reload.
+func (c *Config) reloadSignalName(i Interface) string {
+	name := strings.TrimPrefix(strings.ToUpper(c.Option.string(optionReloadSignal, "")), "SIG")
+	if len(name) == 0 {
+		if _, ok := i.(Reloader); ok {
+			name = "HUP"
+		}
+	}
+	return name
+}
+
 // TODO: Add Configure to Service interface.
 
 // Service represents a service that can be run or controlled.
@@ -308,6 +561,10 @@ type Service interface {
 	// Restart signals to the OS service manager the given service should stop then start.
 	Restart() error
 
+	// Reload signals to the OS service manager the given service should reload
+	// its configuration without stopping.
+	Reload() error
+
 	// Install setups up the given service in the OS service manager. This may require
 	// greater rights. Will return an error if it is already installed.
 	Install() error
@@ -334,21 +591,23 @@ type Service interface {
 	// otherwise the name.
 	String() string
 
-	// Status returns nil if the given service is running.
-	// Will return an error if the service is not running or is not present.
-	Status() (string, error)
+	// Status returns the current state of the service as reported by the
+	// OS service manager. A stopped or missing service is reported through
+	// ServiceStatus.State; an error is only returned if the state could not
+	// be determined.
+	Status() (ServiceStatus, error)
 	// PID returns pid if the given service is running.
 	// Will return an error if the service is not running or is not present.
 	PID() (int, error)
 }
 
 // ControlAction list valid string texts to use in Control.
-var ControlAction = [6]string{"start", "stop", "restart", "install", "uninstall", "status"}
+var ControlAction = [7]string{"start", "stop", "restart", "install", "uninstall", "status", "reload"}
 
 // Control issues control functions to the service from a given action string.
 func Control(s Service, action string) error {
 	var err error
-	var status string
+	var status ServiceStatus
 	switch action {
 	case ControlAction[0]:
 		err = s.Start()
@@ -363,6 +622,8 @@ func Control(s Service, action string) error {
 	case ControlAction[5]:
 		status, err = s.Status()
 		fmt.Println(status)
+	case ControlAction[6]:
+		err = s.Reload()
 	default:
 		err = fmt.Errorf("Unknown action %s", action)
 	}
@@ -372,6 +633,60 @@ func Control(s Service, action string) error {
 	return nil
 }
 
+// State is the run state of a service.
+type State int
+
+// States a service may be reported in by Service.Status.
+const (
+	StateUnknown State = iota
+	StateRunning
+	StateStopped
+	StateFailed
+	StateActivating
+	StateNotInstalled
+)
+
+func (st State) String() string {
+	switch st {
+	case StateRunning:
+		return "running"
+	case StateStopped:
+		return "stopped"
+	case StateFailed:
+		return "failed"
+	case StateActivating:
+		return "activating"
+	case StateNotInstalled:
+		return "not installed"
+	default:
+		return "unknown"
+	}
+}
+
+// ServiceStatus describes a service as reported by the OS service manager.
+// Fields a backend cannot determine are left at their zero value.
+type ServiceStatus struct {
+	State        State
+	SubState     string    // Backend specific detail of State, such as "auto-restart" on systemd.
+	LoadState    string    // If the backend loaded the service definition, such as "loaded" on systemd.
+	PID          int       // Main process ID, 0 if not running.
+	StartTime    time.Time // When the main process was started.
+	RestartCount int       // Number of automatic restarts.
+	ExitCode     int       // Exit code of the last run.
+	Result       string    // Outcome of the last run, such as "success" or "exit-code" on systemd.
+	LastRun      time.Time // Last start of a scheduled service.
+	NextRun      time.Time // Next start of a scheduled service.
+	Backend      string    // Name of the System that reported the status.
+}
+
+// String formats the status as "running (pid: 123)".
+func (ss ServiceStatus) String() string {
+	if ss.PID > 0 {
+		return ss.State.String() + " (pid: " + strconv.Itoa(ss.PID) + ")"
+	}
+	return ss.State.String()
+}
+
 // Logger writes to the system log.
 type Logger interface {
 	Error(v ...interface{}) error
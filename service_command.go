@@ -0,0 +1,80 @@
+// Copyright 2015 Daniel Theophanes.
+// Use of this source code is governed by a zlib-style
+// license that can be found in the LICENSE file.
+
+package service
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os/exec"
+)
+
+// execCommander runs commands on the host.
+type execCommander struct{}
+
+func (execCommander) Run(name string, args ...string) error {
+	return run(name, args...)
+}
+
+func (execCommander) Output(name string, args ...string) ([]byte, error) {
+	return runWithOutput(name, args...)
+}
+
+func (c *Config) commander() Commander {
+	if c.Commander != nil {
+		return c.Commander
+	}
+	return execCommander{}
+}
+
+// run runs a command of the service system through the Commander.
+func (c *Config) run(name string, args ...string) error {
+	return c.commander().Run(name, args...)
+}
+
+// runWithOutput runs a command of the service system through the Commander
+// and returns its combined output.
+func (c *Config) runWithOutput(name string, args ...string) ([]byte, error) {
+	return c.commander().Output(name, args...)
+}
+
+func run(command string, arguments ...string) error {
+	cmd := exec.Command(command, arguments...)
+
+	// Connect pipe to read Stderr
+	stderr, err := cmd.StderrPipe()
+
+	if err != nil {
+		// Failed to connect pipe
+		return fmt.Errorf("%q failed to connect stderr pipe: %v", command, err)
+	}
+
+	// Do not use cmd.Run()
+	if err := cmd.Start(); err != nil {
+		// Problem while copying stdin, stdout, or stderr
+		return fmt.Errorf("%q failed: %v", command, err)
+	}
+
+	// Zero exit status
+	// Darwin: launchctl can fail with a zero exit status,
+	// so check for emtpy stderr
+	if command == "launchctl" {
+		slurp, _ := ioutil.ReadAll(stderr)
+		if len(slurp) > 0 {
+			return fmt.Errorf("%q failed with stderr: %s", command, slurp)
+		}
+	}
+
+	if err := cmd.Wait(); err != nil {
+		// Command didn't exit with a zero exit status.
+		return fmt.Errorf("%q failed: %v", command, err)
+	}
+
+	return nil
+}
+
+func runWithOutput(command string, arguments ...string) ([]byte, error) {
+	cmd := exec.Command(command, arguments...)
+	return cmd.CombinedOutput()
+}
@@ -0,0 +1,161 @@
+// Copyright 2015 Daniel Theophanes.
+// Use of this source code is governed by a zlib-style
+// license that can be found in the LICENSE file.
+
+package service_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/isaaxiot/service"
+	"github.com/isaaxiot/service/servicetest"
+)
+
+// newWithCommander creates a service for the named system that runs its
+// commands through cmd.
+func newWithCommander(t *testing.T, name string, cmd service.Commander) service.Service {
+	return newWithConfig(t, name, &service.Config{Name: "go_service_test", Commander: cmd})
+}
+
+// newWithConfig creates a service for the named system from c.
+func newWithConfig(t *testing.T, name string, c *service.Config) service.Service {
+	for _, sys := range service.AvailableSystems() {
+		if sys.String() != name {
+			continue
+		}
+		s, err := sys.New(&program{}, c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+	t.Fatalf("system %s not available", name)
+	return nil
+}
+
+func TestCommanderPID(t *testing.T) {
+	// pid is -1 if the service is not running, 0 if it runs without a PID.
+	tests := []struct {
+		system  string
+		command string
+		output  string
+		pid     int
+	}{
+		{"linux-systemd", "systemctl show -p ActiveState,MainPID go_service_test.service", "ActiveState=active\nMainPID=1234\n", 1234},
+		{"linux-systemd", "systemctl show -p ActiveState,MainPID go_service_test.service", "ActiveState=inactive\nMainPID=0\n", -1},
+		{"linux-systemd", "systemctl show -p ActiveState,MainPID go_service_test.service", "ActiveState=active\nMainPID=0\n", 0},
+		{"linux-upstart", "status go_service_test", "go_service_test start/running, process 1234\n", 1234},
+		{"linux-upstart", "status go_service_test", "go_service_test stop/waiting\n", -1},
+		{"linux-runit", "sv status /var/service/go_service_test", "run: /var/service/go_service_test: (pid 1234) 56s; run: log: (pid 1233) 56s\n", 1234},
+		{"linux-runit", "sv status /var/service/go_service_test", "down: /var/service/go_service_test: 3s, normally up\n", -1},
+	}
+	for _, tt := range tests {
+		cmd := &servicetest.Commander{}
+		cmd.Respond(tt.command, tt.output, 0)
+		s := newWithCommander(t, tt.system, cmd)
+		pid, err := s.PID()
+		if tt.pid == 0 {
+			if err == nil || err == service.ErrServiceIsNotRunning {
+				t.Errorf("%s PID: %d, %v, want an error", tt.system, pid, err)
+			}
+			continue
+		}
+		if tt.pid < 0 {
+			if err != service.ErrServiceIsNotRunning {
+				t.Errorf("%s PID err: %v, want %v", tt.system, err, service.ErrServiceIsNotRunning)
+			}
+			continue
+		}
+		if err != nil || pid != tt.pid {
+			t.Errorf("%s PID: %d, %v, want %d", tt.system, pid, err, tt.pid)
+		}
+	}
+}
+
+func TestCommanderStatus(t *testing.T) {
+	cmd := &servicetest.Commander{}
+	cmd.Respond("systemctl show -p LoadState,ActiveState,SubState,MainPID,ExecMainStartTimestamp,NRestarts,Result,ExecMainStatus go_service_test.service",
+		"LoadState=loaded\nActiveState=activating\nSubState=auto-restart\nMainPID=0\nExecMainStartTimestamp=Thu 2017-05-04 02:00:00 UTC\nNRestarts=3\nResult=exit-code\nExecMainStatus=2\n", 0)
+	s := newWithCommander(t, "linux-systemd", cmd)
+	status, err := s.Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.State != service.StateActivating || status.SubState != "auto-restart" || status.LoadState != "loaded" {
+		t.Errorf("state %v %s %s, want activating auto-restart loaded", status.State, status.SubState, status.LoadState)
+	}
+	if status.RestartCount != 3 || status.Result != "exit-code" || status.ExitCode != 2 || status.PID != 0 {
+		t.Errorf("last run %+v, want 3 restarts and exit-code 2", status)
+	}
+	if status.StartTime.IsZero() {
+		t.Error("start time not parsed")
+	}
+}
+
+func TestCommanderControl(t *testing.T) {
+	cmd := &servicetest.Commander{}
+	s := newWithCommander(t, "linux-systemd", cmd)
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	cmd.Respond("systemctl stop go_service_test.service", "", 1)
+	if err := s.Stop(); err == nil {
+		t.Error("Stop should fail when systemctl fails")
+	}
+	want := []string{"systemctl start go_service_test.service", "systemctl stop go_service_test.service"}
+	if got := cmd.Lines(); len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("commands run: %q, want %q", got, want)
+	}
+}
+
+func TestCommanderReload(t *testing.T) {
+	cmd := &servicetest.Commander{}
+	s := newWithCommander(t, "linux-upstart", cmd)
+	if err := s.Reload(); err == nil {
+		t.Error("Reload should fail without a ReloadSignal")
+	}
+	if got := cmd.Lines(); len(got) != 0 {
+		t.Errorf("commands run: %q, want none", got)
+	}
+}
+
+func TestCommanderUninstallTimer(t *testing.T) {
+	// A user service keeps its units in XDG_CONFIG_HOME, away from /etc.
+	dir, err := ioutil.TempDir("", "go_service_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer os.Setenv("XDG_CONFIG_HOME", os.Getenv("XDG_CONFIG_HOME"))
+	os.Setenv("XDG_CONFIG_HOME", dir)
+	units := filepath.Join(dir, "systemd", "user")
+	if err := os.MkdirAll(units, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"go_service_test.service", "go_service_test.timer"} {
+		if err := ioutil.WriteFile(filepath.Join(units, name), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cmd := &servicetest.Commander{}
+	s := newWithConfig(t, "linux-systemd", &service.Config{
+		Name:      "go_service_test",
+		Schedule:  "daily",
+		Option:    service.KeyValue{"UserService": true},
+		Commander: cmd,
+	})
+	if err := s.Uninstall(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(units, "go_service_test.timer")); !os.IsNotExist(err) {
+		t.Errorf("timer unit not removed: %v", err)
+	}
+	want := []string{"systemctl --user stop go_service_test.timer", "systemctl --user disable go_service_test.timer"}
+	if got := cmd.Lines(); len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("commands run: %q, want %q", got, want)
+	}
+}
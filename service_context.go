@@ -0,0 +1,75 @@
+// Copyright 2015 Daniel Theophanes.
+// Use of this source code is governed by a zlib-style
+// license that can be found in the LICENSE file.
+
+package service
+
+import (
+	"context"
+	"os"
+	"time"
+)
+
+// startProgram calls Start, or StartContext if i implements ContextInterface,
+// and waits at most the StartTimeout option for it to return.
+func (c *Config) startProgram(i Interface, s Service) error {
+	timeout := c.Option.duration(optionStartTimeout, optionStartTimeoutDefault)
+	return c.callWithTimeout(timeout, ErrStartTimeout, func(ctx context.Context) error {
+		if ci, ok := i.(ContextInterface); ok {
+			return ci.StartContext(ctx, s)
+		}
+		return i.Start(s)
+	})
+}
+
+// stopProgram calls Stop, or StopContext if i implements ContextInterface,
+// and waits at most the StopTimeout option for it to return.
+func (c *Config) stopProgram(i Interface, s Service) error {
+	timeout := c.Option.duration(optionStopTimeout, optionStopTimeoutDefault)
+	return c.callWithTimeout(timeout, ErrStopTimeout, func(ctx context.Context) error {
+		if ci, ok := i.(ContextInterface); ok {
+			return ci.StopContext(ctx, s)
+		}
+		return i.Stop(s)
+	})
+}
+
+// callWithTimeout runs f with a context that is cancelled after timeout.
+// If f has not returned by then timeoutErr is returned and f is left running.
+// A timeout of zero or less waits for f indefinitely.
+func (c *Config) callWithTimeout(timeout time.Duration, timeoutErr error, f func(ctx context.Context) error) error {
+	if timeout <= 0 {
+		return f(context.Background())
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- f(ctx)
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		if c.Option.bool(optionExitOnTimeout, optionExitOnTimeoutDefault) {
+			os.Exit(1)
+		}
+		return timeoutErr
+	}
+}
+
+// timeoutSeconds returns the duration of the given option rounded up to whole
+// seconds, as used by init system configuration files.
+func (c *Config) timeoutSeconds(name string, defaultValue time.Duration) int {
+	return seconds(c.Option.duration(name, defaultValue))
+}
+
+// seconds returns d rounded up to whole seconds, or 0 if d is not positive.
+func seconds(d time.Duration) int {
+	if d <= 0 {
+		return 0
+	}
+	return int((d + time.Second - 1) / time.Second)
+}
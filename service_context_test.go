@@ -0,0 +1,48 @@
+// Copyright 2015 Daniel Theophanes.
+// Use of this source code is governed by a zlib-style
+// license that can be found in the LICENSE file.
+
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+type contextProgram struct{}
+
+func (p *contextProgram) Start(s Service) error {
+	return nil
+}
+func (p *contextProgram) Stop(s Service) error {
+	select {}
+}
+func (p *contextProgram) StartContext(ctx context.Context, s Service) error {
+	return nil
+}
+func (p *contextProgram) StopContext(ctx context.Context, s Service) error {
+	// Overrun the deadline.
+	<-ctx.Done()
+	time.Sleep(time.Second)
+	return nil
+}
+
+func TestStopTimeout(t *testing.T) {
+	p := &contextProgram{}
+	c := &Config{
+		Name: "go_service_test",
+		Option: KeyValue{
+			optionStopTimeout: 50 * time.Millisecond,
+		},
+	}
+	if err := c.startProgram(p, nil); err != nil {
+		t.Fatalf("startProgram err: %v", err)
+	}
+	if err := c.stopProgram(p, nil); err != ErrStopTimeout {
+		t.Fatalf("stopProgram err: %v, want %v", err, ErrStopTimeout)
+	}
+	if got := c.timeoutSeconds(optionStopTimeout, optionStopTimeoutDefault); got != 1 {
+		t.Errorf("timeoutSeconds: %d, want 1", got)
+	}
+}
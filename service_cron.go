@@ -0,0 +1,119 @@
+// Copyright 2015 Daniel Theophanes.
+// Use of this source code is governed by a zlib-style
+// license that can be found in the LICENSE file.
+
+package service
+
+import (
+	"bytes"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// cron runs a scheduled service from an /etc/cron.d entry, on the Linux
+// systems without timers. system is the name of the system it replaces.
+type cron struct {
+	i Interface
+	*Config
+	system string
+}
+
+var cronFileRe = regexp.MustCompile(`[^A-Za-z0-9_-]`)
+
+// configPath returns the path of the cron.d file. cron ignores files with
+// other characters than letters, digits, underscores and hyphens.
+func (s *cron) configPath() string {
+	return "/etc/cron.d/" + cronFileRe.ReplaceAllString(s.Name, "_")
+}
+
+// command returns the shell command of the crontab entry. The environment is
+// exported by the shell, as cron.d files do not support quoting everywhere.
+func (s *cron) command() (string, error) {
+	path, err := s.execPath()
+	if err != nil {
+		return "", err
+	}
+	args := s.Arguments
+	if cmd := strings.Split(path, " "); len(cmd) > 1 {
+		path = cmd[0]
+		args = append(cmd[1:], args...)
+	}
+
+	var cmd []string
+	names := make([]string, 0, len(s.Envs))
+	for name := range s.Envs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		cmd = append(cmd, "export", name+"="+shquote(s.Envs[name])+";")
+	}
+	cmd = append(cmd, "set", "-a;", "[", "-e", s.envPath(), "]", "&&", ".", s.envPath()+";", "set", "+a;")
+	if len(s.WorkingDirectory) > 0 {
+		cmd = append(cmd, "cd", shquote(s.WorkingDirectory), "&&")
+	}
+	cmd = append(cmd, "exec", shquote(path))
+	for _, arg := range args {
+		cmd = append(cmd, shquote(arg))
+	}
+	stdout := s.Option.string(optionStandardOutPath, "")
+	stderr := s.Option.string(optionStandardErrorPath, "")
+	switch {
+	case len(stdout) > 0 && len(stderr) > 0:
+		cmd = append(cmd, ">>"+shquote(stdout), "2>>"+shquote(stderr))
+	case len(stdout) > 0:
+		cmd = append(cmd, ">>"+shquote(stdout), "2>&1")
+	}
+	return strings.Join(cmd, " "), nil
+}
+
+func (s *cron) template() *template.Template {
+	return template.Must(template.New("").Funcs(tf).Parse(cronScript))
+}
+
+// Render returns the cron.d file created by Install.
+func (s *cron) Render() (*Manifest, error) {
+	schedule, err := s.cronSchedule()
+	if err != nil {
+		return nil, err
+	}
+	cmd, err := s.command()
+	if err != nil {
+		return nil, err
+	}
+	user := "root"
+	if len(s.UserName) > 0 {
+		user = strings.SplitN(s.UserName, ":", 2)[0]
+	}
+
+	var to = &struct {
+		*Config
+		Cron    string
+		User    string
+		Command string
+	}{
+		s.Config,
+		schedule.String(),
+		user,
+		// % starts the standard input of the command in crontab entries.
+		strings.Replace(cmd, "%", `\%`, -1),
+	}
+
+	var buf bytes.Buffer
+	if err := s.template().Execute(&buf, to); err != nil {
+		return nil, err
+	}
+	return &Manifest{
+		Files: []ManifestFile{{Path: s.configPath(), Mode: 0644, Content: buf.Bytes()}},
+	}, nil
+}
+
+const cronScript = `# {{.Description}}
+# Runs {{.Name}} on the schedule {{.Schedule}}.
+SHELL=/bin/sh
+PATH=/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin
+
+{{.Cron}} {{.User}} {{.Command}}
+`
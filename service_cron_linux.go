@@ -0,0 +1,138 @@
+// Copyright 2015 Daniel Theophanes.
+// Use of this source code is governed by a zlib-style
+// license that can be found in the LICENSE file.
+
+package service
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+func newCronService(i Interface, c *Config, system string) (Service, error) {
+	s := &cron{
+		i:      i,
+		Config: c,
+		system: system,
+	}
+
+	return s, nil
+}
+
+func (s *cron) String() string {
+	if len(s.DisplayName) > 0 {
+		return s.DisplayName
+	}
+	return s.Name
+}
+
+func (s *cron) Install() error {
+	_, err := s.install()
+	return err
+}
+
+func (s *cron) install() (*Manifest, error) {
+	if err := s.Validate(system); err != nil {
+		return nil, err
+	}
+	confPath := s.configPath()
+	_, err := os.Stat(filepath.Join(s.installRoot(), confPath))
+	if err == nil {
+		return nil, fmt.Errorf("Init already exists: %s", confPath)
+	}
+
+	m, err := s.Render()
+	if err != nil {
+		return nil, err
+	}
+	return s.apply(m)
+}
+
+func (s *cron) Uninstall() error {
+	return os.Remove(s.configPath())
+}
+
+func (s *cron) Logger(errs chan<- error) (Logger, error) {
+	if system.Interactive() {
+		return ConsoleLogger, nil
+	}
+	return s.SystemLogger(errs)
+}
+
+func (s *cron) SystemLogger(errs chan<- error) (Logger, error) {
+	return newSysLogger(s.Name, errs)
+}
+
+// Run calls Start and then Stop, cron starts a new process for each run.
+func (s *cron) Run() error {
+	if err := s.startProgram(s.i, s); err != nil {
+		return err
+	}
+	return s.stopProgram(s.i, s)
+}
+
+// Start returns ErrScheduled, cron starts the service.
+func (s *cron) Start() error {
+	return ErrScheduled
+}
+
+// Stop returns ErrScheduled, each run stops by itself.
+func (s *cron) Stop() error {
+	return ErrScheduled
+}
+
+func (s *cron) Restart() error {
+	return ErrScheduled
+}
+
+func (s *cron) Reload() error {
+	return ErrScheduled
+}
+
+// Update rewrites the cron.d file if it differs from what Install would
+// write, cron reads it again by itself.
+func (s *cron) Update() error {
+	return updateService(s)
+}
+
+// Status reports a stopped service, cron does not track the runs. LastRun is
+// the last time cron was due to start the service since it was installed.
+func (s *cron) Status() (ServiceStatus, error) {
+	status := ServiceStatus{Backend: s.system}
+	fi, err := os.Stat(s.configPath())
+	if os.IsNotExist(err) {
+		status.State = StateNotInstalled
+		return status, nil
+	}
+	if err != nil {
+		return status, err
+	}
+	status.State = StateStopped
+
+	schedule, err := s.cronSchedule()
+	if err != nil {
+		return status, err
+	}
+	now := time.Now()
+	if last := schedule.prev(now); last.After(fi.ModTime()) {
+		status.LastRun = last
+	}
+	status.NextRun = schedule.next(now)
+	return status, nil
+}
+
+func (s *cron) PID() (int, error) {
+	return -1, ErrServiceIsNotRunning
+}
+
+// GetEnv returns the variables of /etc/sysconfig/<name>. See EnvManager.
+func (s *cron) GetEnv() (map[string]string, error) {
+	return readEnvFile(s.envPath())
+}
+
+// SetEnv replaces the variables of /etc/sysconfig/<name>. See EnvManager.
+func (s *cron) SetEnv(env map[string]string) error {
+	return writeEnvFile(s.envPath(), env)
+}
@@ -5,24 +5,18 @@
 package service
 
 import (
-	"errors"
 	"fmt"
 	"os"
-	"os/exec"
-	"os/signal"
-	"os/user"
 	"path/filepath"
 	"regexp"
 	"strconv"
-	"strings"
 	"syscall"
-	"text/template"
 	"time"
 )
 
 const maxPathSize = 32 * 1024
 
-const version = "darwin-launchd"
+const version = launchdName
 
 type darwinSystem struct{}
 
@@ -35,6 +29,13 @@ func (darwinSystem) Detect() bool {
 func (darwinSystem) Interactive() bool {
 	return interactive
 }
+func (darwinSystem) validateConfig(c *Config) []error {
+	var errs []error
+	if len(c.Schedule) != 0 {
+		errs = append(errs, errUnsupported("Config.Schedule", version))
+	}
+	return errs
+}
 func (darwinSystem) New(i Interface, c *Config) (Service, error) {
 	s := &darwinLaunchdService{
 		i:      i,
@@ -65,13 +66,6 @@ func isInteractive() (bool, error) {
 	return os.Getppid() != 1, nil
 }
 
-type darwinLaunchdService struct {
-	i Interface
-	*Config
-
-	userService bool
-}
-
 // Is a service installed
 func (s *darwinLaunchdService) IsInstalled() bool {
 	confPath, err := s.getServiceFilePath()
@@ -92,98 +86,37 @@ func (s *darwinLaunchdService) String() string {
 	return s.Name
 }
 
-func (s *darwinLaunchdService) getHomeDir() (string, error) {
-	u, err := user.Current()
-	if err == nil {
-		return u.HomeDir, nil
-	}
-
-	// alternate methods
-	homeDir := os.Getenv("HOME") // *nix
-	if homeDir == "" {
-		return "", errors.New("User home directory not found.")
-	}
-	return homeDir, nil
+func (s *darwinLaunchdService) Install() error {
+	_, err := s.install()
+	return err
 }
 
-func (s *darwinLaunchdService) getServiceFilePath() (string, error) {
-	if s.userService {
-		homeDir, err := s.getHomeDir()
-		if err != nil {
-			return "", err
-		}
-		return homeDir + "/Library/LaunchAgents/" + s.Name + ".plist", nil
+func (s *darwinLaunchdService) install() (*Manifest, error) {
+	if err := s.Validate(system); err != nil {
+		return nil, err
 	}
-	return "/Library/LaunchDaemons/" + s.Name + ".plist", nil
-}
-
-func (s *darwinLaunchdService) Install() error {
 	confPath, err := s.getServiceFilePath()
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	if s.IsInstalled() {
-		return fmt.Errorf("Init already exists: %s", confPath)
+	root := s.installRoot()
+	if _, err := os.Stat(filepath.Join(root, confPath)); err == nil {
+		return nil, fmt.Errorf("Init already exists: %s", confPath)
 	}
 
 	if s.userService {
 		// Ensure that ~/Library/LaunchAgents exists.
-		if err := os.MkdirAll(filepath.Dir(confPath), 0700); err != nil {
-			return err
+		if err := os.MkdirAll(filepath.Join(root, filepath.Dir(confPath)), 0700); err != nil {
+			return nil, err
 		}
 	}
 
-	f, err := os.Create(confPath)
-	if err != nil {
-		return err
-	}
-	defer f.Close()
-
-	path, err := s.execPath()
+	m, err := s.Render()
 	if err != nil {
-		return err
-	}
-
-	cmd := strings.Split(path, " ")
-	if len(cmd) > 1 {
-		path = cmd[0]
-		s.Config.Arguments = append(cmd[1:], s.Config.Arguments...)
-	}
-	if filepath.Base(path) == path { //check IsAbs
-		if newpath, err := exec.LookPath(path); err == nil {
-			path = newpath
-		}
+		return nil, err
 	}
-
-	var to = &struct {
-		*Config
-		Path string
-
-		KeepAlive, RunAtLoad bool
-		SessionCreate        bool
-		StandardOutPath      string
-		StandardErrorPath    string
-	}{
-		Config:            s.Config,
-		Path:              path,
-		KeepAlive:         s.Option.bool(optionKeepAlive, optionKeepAliveDefault),
-		RunAtLoad:         s.Option.bool(optionRunAtLoad, optionRunAtLoadDefault),
-		SessionCreate:     s.Option.bool(optionSessionCreate, optionSessionCreateDefault),
-		StandardOutPath:   s.Option.string(optionStandardOutPath, ""),
-		StandardErrorPath: s.Option.string(optionStandardErrorPath, ""),
-	}
-
-	functions := template.FuncMap{
-		"bool": func(v bool) string {
-			if v {
-				return "true"
-			}
-			return "false"
-		},
-	}
-	t := template.Must(template.New("launchdConfig").Funcs(functions).Parse(launchdConfig))
-	return t.Execute(f, to)
+	return s.apply(m)
 }
 
 func (s *darwinLaunchdService) Uninstall() error {
@@ -198,7 +131,7 @@ func (s *darwinLaunchdService) Uninstall() error {
 		return err
 	}
 
-	run("launchctl", "remove", confPath)
+	s.run("launchctl", "remove", confPath)
 
 	return os.Remove(confPath)
 }
@@ -208,7 +141,7 @@ func (s *darwinLaunchdService) Start() error {
 	if err != nil {
 		return err
 	}
-	return run("launchctl", "load", confPath)
+	return s.run("launchctl", "load", confPath)
 }
 
 func (s *darwinLaunchdService) Stop() error {
@@ -216,7 +149,7 @@ func (s *darwinLaunchdService) Stop() error {
 	if err != nil {
 		return err
 	}
-	run("launchctl", "unload", confPath)
+	s.run("launchctl", "unload", confPath)
 	return nil
 }
 
@@ -229,21 +162,30 @@ func (s *darwinLaunchdService) Restart() error {
 	return s.Start()
 }
 
+func (s *darwinLaunchdService) Reload() error {
+	pid, err := s.checkRunning()
+	if err != nil {
+		return err
+	}
+	if pid <= 0 {
+		return ErrServiceIsNotRunning
+	}
+	return s.signalReload(s.i, pid)
+}
+
 func (s *darwinLaunchdService) Run() error {
 	var err error
 
-	err = s.i.Start(s)
+	err = s.startProgram(s.i, s)
 	if err != nil {
 		return err
 	}
 
 	s.Option.funcSingle(optionRunWait, func() {
-		var sigChan = make(chan os.Signal, 3)
-		signal.Notify(sigChan, syscall.SIGTERM, os.Interrupt)
-		<-sigChan
+		s.waitForSignal(s.i, s, syscall.SIGTERM, os.Interrupt)
 	})()
 
-	return s.i.Stop(s)
+	return s.stopProgram(s.i, s)
 }
 
 func (s *darwinLaunchdService) Logger(errs chan<- error) (Logger, error) {
@@ -261,14 +203,15 @@ func (s *darwinLaunchdService) PID() (int, error) {
 	return s.checkRunning()
 }
 
+// Update rewrites the property list if it differs from what Install would
+// write. launchd reads it when the service is started again.
 func (s *darwinLaunchdService) Update() error {
-	s.Uninstall()
-	return s.Install()
+	return updateService(s)
 }
 
 // Check service is running
 func (s *darwinLaunchdService) checkRunning() (int, error) {
-	output, err := exec.Command("launchctl", "list", s.Name).Output()
+	output, err := s.runWithOutput("launchctl", "list", s.Name)
 	if err != nil {
 		return -1, err
 	}
@@ -283,47 +226,37 @@ func (s *darwinLaunchdService) checkRunning() (int, error) {
 	return -1, ErrServiceIsNotRunning
 }
 
-func (s *darwinLaunchdService) Status() (string, error) {
+var (
+	launchdPIDRe        = regexp.MustCompile(`"PID" = ([0-9]+);`)
+	launchdExitStatusRe = regexp.MustCompile(`"LastExitStatus" = ([0-9]+);`)
+)
+
+func (s *darwinLaunchdService) Status() (ServiceStatus, error) {
+	status := ServiceStatus{Backend: version}
 	if !s.IsInstalled() {
-		return "", ErrServiceIsNotInstalled
+		status.State = StateNotInstalled
+		return status, nil
 	}
 
-	pid, err := s.checkRunning()
+	output, err := s.runWithOutput("launchctl", "list", s.Name)
 	if err != nil {
-		return "", err
+		if _, ok := exitStatus(err); !ok {
+			return status, err
+		}
+		// The job is not loaded.
+		status.State = StateStopped
+		return status, nil
+	}
+	if data := launchdExitStatusRe.FindSubmatch(output); len(data) > 1 {
+		status.ExitCode, _ = strconv.Atoi(string(data[1]))
 	}
-	return "running (pid: " + strconv.Itoa(pid) + ")", nil
+	if data := launchdPIDRe.FindSubmatch(output); len(data) > 1 {
+		status.State = StateRunning
+		status.PID, _ = strconv.Atoi(string(data[1]))
+	} else if status.ExitCode != 0 {
+		status.State = StateFailed
+	} else {
+		status.State = StateStopped
+	}
+	return status, nil
 }
-
-var launchdConfig = `<?xml version='1.0' encoding='UTF-8'?>
-<!DOCTYPE plist PUBLIC "-//Apple Computer//DTD PLIST 1.0//EN"
-"http://www.apple.com/DTDs/PropertyList-1.0.dtd" >
-<plist version='1.0'>
-<dict>
-<key>Label</key><string>{{html .Name}}</string>
-    <key>EnvironmentVariables</key>
-    <dict>
-{{ range $key, $value := .Config.Envs }}<key>{{ $key }}</key>
-        <string>{{ $value }}</string>
-{{ end }}
-    </dict>
-
-<key>ProgramArguments</key>
-<array>
-        <string>{{html .Path}}</string>
-{{range .Config.Arguments}}
-        <string>{{html .}}</string>
-{{end}}
-</array>
-{{if .UserName}}<key>UserName</key><string>{{html .UserName}}</string>{{end}}
-{{if .ChRoot}}<key>RootDirectory</key><string>{{html .ChRoot}}</string>{{end}}
-{{if .WorkingDirectory}}<key>WorkingDirectory</key><string>{{html .WorkingDirectory}}</string>{{end}}
-{{if .StandardOutPath}}<key>StandardOutPath</key><string>{{html .StandardOutPath}}</string>{{end}}
-{{if .StandardErrorPath}}<key>StandardErrorPath</key><string>{{html .StandardErrorPath}}</string>{{end}}
-<key>SessionCreate</key><{{bool .SessionCreate}}/>
-<key>KeepAlive</key><{{bool .KeepAlive}}/>
-<key>RunAtLoad</key><{{bool .RunAtLoad}}/>
-<key>Disabled</key><false/>
-</dict>
-</plist>
-`
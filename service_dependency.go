@@ -0,0 +1,148 @@
+// Copyright 2015 Daniel Theophanes.
+// Use of this source code is governed by a zlib-style
+// license that can be found in the LICENSE file.
+
+package service
+
+import (
+	"fmt"
+	"strings"
+)
+
+// dependencyKind tells how strongly a service depends on another.
+type dependencyKind int
+
+const (
+	dependRequires dependencyKind = iota // Start after it, fail without it.
+	dependWants                          // Start after it if it is available.
+	dependAfter                          // Only start after it.
+)
+
+var dependencyPrefixes = map[string]dependencyKind{
+	"Requires": dependRequires,
+	"Wants":    dependWants,
+	"After":    dependAfter,
+}
+
+// dependency is a service named in Config.Dependencies.
+type dependency struct {
+	name string
+	kind dependencyKind
+}
+
+// dependencies parses Config.Dependencies. Unknown prefixes are reported by
+// Validate and ignored here.
+func (c *Config) dependencies() []dependency {
+	var deps []dependency
+	for _, entry := range c.Dependencies {
+		kind := dependRequires
+		if pos := strings.Index(entry, "="); pos != -1 {
+			var known bool
+			if kind, known = dependencyPrefixes[entry[:pos]]; !known {
+				continue
+			}
+			entry = entry[pos+1:]
+		}
+		for _, name := range strings.Fields(entry) {
+			deps = append(deps, dependency{name: name, kind: kind})
+		}
+	}
+	return deps
+}
+
+// dependencyNames returns the names of the dependencies of the given kinds.
+func (c *Config) dependencyNames(kinds ...dependencyKind) []string {
+	var names []string
+	for _, d := range c.dependencies() {
+		for _, kind := range kinds {
+			if d.kind == kind {
+				names = append(names, d.name)
+			}
+		}
+	}
+	return names
+}
+
+// dependencyEntries returns the entries of the given kinds without their
+// prefix. Unlike dependencyNames it keeps the entries whole, for systems
+// whose service names may contain spaces.
+func (c *Config) dependencyEntries(kinds ...dependencyKind) []string {
+	var names []string
+	for _, entry := range c.Dependencies {
+		kind := dependRequires
+		if pos := strings.Index(entry, "="); pos != -1 {
+			var known bool
+			if kind, known = dependencyPrefixes[entry[:pos]]; !known {
+				continue
+			}
+			entry = entry[pos+1:]
+		}
+		if entry = strings.TrimSpace(entry); len(entry) == 0 {
+			continue
+		}
+		for _, k := range kinds {
+			if k == kind {
+				names = append(names, entry)
+			}
+		}
+	}
+	return names
+}
+
+func (c *Config) validateDependencies() []error {
+	var errs []error
+	for _, entry := range c.Dependencies {
+		if pos := strings.Index(entry, "="); pos != -1 {
+			if _, known := dependencyPrefixes[entry[:pos]]; !known {
+				errs = append(errs, fmt.Errorf("Config.Dependencies entry %q must start with Requires=, Wants= or After=.", entry))
+			}
+		}
+	}
+	return errs
+}
+
+// lsbFacilities maps the LSB init script facilities to systemd units.
+var lsbFacilities = map[string]string{
+	"$local_fs":  "local-fs.target",
+	"$network":   "network-online.target",
+	"$named":     "nss-lookup.target",
+	"$portmap":   "rpcbind.target",
+	"$remote_fs": "remote-fs.target",
+	"$syslog":    "syslog.service",
+	"$time":      "time-sync.target",
+}
+
+// systemdUnit returns the unit name of a dependency. Names without a unit
+// type are services.
+func systemdUnit(name string) string {
+	if unit, found := lsbFacilities[name]; found {
+		return unit
+	}
+	if strings.Contains(name, ".") {
+		return name
+	}
+	return name + ".service"
+}
+
+// lsbName returns the LSB init script name or facility of a dependency.
+func lsbName(name string) string {
+	for facility, unit := range lsbFacilities {
+		if name == unit {
+			return facility
+		}
+	}
+	if name == "network.target" {
+		return "$network"
+	}
+	return strings.TrimSuffix(name, ".service")
+}
+
+// jobName returns the name of a dependency that is an init job, or "" for
+// facilities and systemd targets.
+func jobName(name string) string {
+	name = lsbName(name)
+	if strings.HasPrefix(name, "$") || strings.Contains(name, ".") {
+		return ""
+	}
+	return name
+}
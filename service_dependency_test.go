@@ -0,0 +1,27 @@
+// Copyright 2015 Daniel Theophanes.
+// Use of this source code is governed by a zlib-style
+// license that can be found in the LICENSE file.
+
+package service
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDependencyNames(t *testing.T) {
+	c := &Config{Dependencies: []string{"postgresql redis", "Wants=$network", "After=syslog.target", "Before=nginx"}}
+	if got, want := c.dependencyNames(dependRequires), []string{"postgresql", "redis"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("dependencyNames(dependRequires) = %q, want %q", got, want)
+	}
+	if got, want := c.dependencyNames(dependWants, dependAfter), []string{"$network", "syslog.target"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("dependencyNames(dependWants, dependAfter) = %q, want %q", got, want)
+	}
+}
+
+func TestDependencyEntries(t *testing.T) {
+	c := &Config{Dependencies: []string{"Requires=Remote Access Manager", "Tcpip", "Wants=Dhcp", "After=Dnscache"}}
+	if got, want := c.dependencyEntries(dependRequires), []string{"Remote Access Manager", "Tcpip"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("dependencyEntries(dependRequires) = %q, want %q", got, want)
+	}
+}
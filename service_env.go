@@ -0,0 +1,192 @@
+// Copyright 2015 Daniel Theophanes.
+// Use of this source code is governed by a zlib-style
+// license that can be found in the LICENSE file.
+
+package service
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// EnvManager is implemented by services that read environment variables
+// from /etc/sysconfig/<name> when they start, in addition to Config.Envs:
+// systemd and System V services, and the cron entries of scheduled
+// services. Changing the file does not change the unit or init script.
+type EnvManager interface {
+	// GetEnv returns the variables of the environment file, empty if
+	// there is no file.
+	GetEnv() (map[string]string, error)
+
+	// SetEnv replaces the variables of the environment file with env,
+	// keeping the comments and the order of the variables already in it.
+	// The file is replaced at once, a service starting meanwhile reads
+	// either the old or the new file. It requires greater rights.
+	// A running service uses the new values once restarted.
+	SetEnv(env map[string]string) error
+}
+
+// ErrNoEnvFile is returned by SetEnv for services that do not implement
+// EnvManager.
+var ErrNoEnvFile = errors.New("Service does not read an environment file.")
+
+// SetEnv sets the environment of s, which must implement EnvManager, and
+// restarts it if restart is true and it is running.
+func SetEnv(s Service, env map[string]string, restart bool) error {
+	em, ok := s.(EnvManager)
+	if !ok {
+		return ErrNoEnvFile
+	}
+	if err := em.SetEnv(env); err != nil {
+		return err
+	}
+	if !restart {
+		return nil
+	}
+	if status, err := s.Status(); err != nil || status.State != StateRunning {
+		return err
+	}
+	return s.Restart()
+}
+
+// envPath returns the path of the environment file of the service.
+func (c *Config) envPath() string {
+	return "/etc/sysconfig/" + c.Name
+}
+
+// readEnvFile parses an environment file, written as shell variable
+// assignments that systemd reads as well.
+func readEnvFile(path string) (map[string]string, error) {
+	env := make(map[string]string)
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return env, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if name, value, ok := parseEnvLine(line); ok {
+			env[name] = value
+		}
+	}
+	return env, nil
+}
+
+// parseEnvLine returns the variable assigned on a line of an environment
+// file. Comments and blank lines are not assignments.
+func parseEnvLine(line string) (name, value string, ok bool) {
+	line = strings.TrimSpace(line)
+	if len(line) == 0 || line[0] == '#' || line[0] == ';' {
+		return "", "", false
+	}
+	line = strings.TrimPrefix(line, "export ")
+	pos := strings.Index(line, "=")
+	if pos == -1 {
+		return "", "", false
+	}
+	name = strings.TrimSpace(line[:pos])
+	if !envNameRe.MatchString(name) {
+		return "", "", false
+	}
+	return name, shunquote(line[pos+1:]), true
+}
+
+// shunquote removes the single and double quotes and the backslashes of a
+// shell word.
+func shunquote(s string) string {
+	var b bytes.Buffer
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote == '\'':
+			if c == '\'' {
+				quote = 0
+			} else {
+				b.WriteByte(c)
+			}
+		case c == '\\' && i+1 < len(s) && (quote == 0 || strings.IndexByte(`"\$`+"`", s[i+1]) != -1):
+			i++
+			b.WriteByte(s[i])
+		case quote == '"':
+			if c == '"' {
+				quote = 0
+			} else {
+				b.WriteByte(c)
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == ' ' || c == '\t':
+			// The rest is not part of the value, such as a comment.
+			return b.String()
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// writeEnvFile replaces the variables of the environment file at path with
+// env.
+func writeEnvFile(path string, env map[string]string) error {
+	for name, value := range env {
+		if !envNameRe.MatchString(name) {
+			return fmt.Errorf("Environment variable name %q is not valid.", name)
+		}
+		if strings.ContainsAny(value, "\r\n") {
+			return fmt.Errorf("Environment variable %s must be a single line.", name)
+		}
+	}
+
+	mode := os.FileMode(0644)
+	data, err := ioutil.ReadFile(path)
+	if err == nil {
+		if fi, err := os.Stat(path); err == nil {
+			mode = fi.Mode().Perm()
+		}
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	var buf bytes.Buffer
+	written := make(map[string]bool)
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	if len(data) == 0 {
+		lines = nil
+	}
+	for _, line := range lines {
+		name, _, ok := parseEnvLine(line)
+		if !ok {
+			buf.WriteString(line + "\n")
+			continue
+		}
+		value, keep := env[name]
+		if !keep || written[name] {
+			continue
+		}
+		buf.WriteString(name + "=" + shquote(value) + "\n")
+		written[name] = true
+	}
+	names := make([]string, 0, len(env))
+	for name := range env {
+		if !written[name] {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		buf.WriteString(name + "=" + shquote(env[name]) + "\n")
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return writeFileAtomic(path, buf.Bytes(), mode)
+}
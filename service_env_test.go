@@ -0,0 +1,74 @@
+// Copyright 2015 Daniel Theophanes.
+// Use of this source code is governed by a zlib-style
+// license that can be found in the LICENSE file.
+
+package service
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestEnvFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go_service_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "sysconfig", "go_service_test")
+
+	env, err := readEnvFile(path)
+	if err != nil || len(env) != 0 {
+		t.Fatalf("missing file: %v, %v, want no variables", env, err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	initial := "# Settings of go_service_test.\nLOG_LEVEL=info\n\nexport ENDPOINT=\"http://old\" # staging\nREMOVED='yes'\n"
+	if err := ioutil.WriteFile(path, []byte(initial), 0600); err != nil {
+		t.Fatal(err)
+	}
+	env, err = readEnvFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]string{"LOG_LEVEL": "info", "ENDPOINT": "http://old", "REMOVED": "yes"}; !reflect.DeepEqual(env, want) {
+		t.Errorf("read %v, want %v", env, want)
+	}
+
+	set := map[string]string{
+		"LOG_LEVEL": "debug",
+		"ENDPOINT":  "http://new",
+		"GREETING":  `it's "$HOME" \ 100%`,
+	}
+	if err := writeEnvFile(path, set); err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "# Settings of go_service_test.\nLOG_LEVEL='debug'\n\nENDPOINT='http://new'\nGREETING='it'\\''s \"$HOME\" \\ 100%'\n"
+	if string(data) != want {
+		t.Errorf("wrote:\n%s\nwant:\n%s", data, want)
+	}
+	if fi, err := os.Stat(path); err != nil || fi.Mode().Perm() != 0600 {
+		t.Errorf("file mode not kept: %v, %v", fi, err)
+	}
+
+	env, err = readEnvFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(env, set) {
+		t.Errorf("read back %v, want %v", env, set)
+	}
+
+	if err := writeEnvFile(path, map[string]string{"BAD NAME": "x"}); err == nil {
+		t.Error("wrote an invalid variable name")
+	}
+}
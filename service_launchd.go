@@ -0,0 +1,157 @@
+// Copyright 2015 Daniel Theophanes.
+// Use of this source code is governed by a zlib-style
+// license that can be found in the LICENSE file.
+
+package service
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+type darwinLaunchdService struct {
+	i Interface
+	*Config
+
+	userService bool
+}
+
+// getHomeDir returns the home directory of the current user.
+func getHomeDir() (string, error) {
+	u, err := user.Current()
+	if err == nil {
+		return u.HomeDir, nil
+	}
+
+	// alternate methods
+	homeDir := os.Getenv("HOME") // *nix
+	if homeDir == "" {
+		return "", errors.New("User home directory not found.")
+	}
+	return homeDir, nil
+}
+
+func (s *darwinLaunchdService) getServiceFilePath() (string, error) {
+	if s.userService {
+		homeDir, err := getHomeDir()
+		if err != nil {
+			return "", err
+		}
+		return homeDir + "/Library/LaunchAgents/" + s.Name + ".plist", nil
+	}
+	return "/Library/LaunchDaemons/" + s.Name + ".plist", nil
+}
+
+// Render returns the property list written by Install.
+func (s *darwinLaunchdService) Render() (*Manifest, error) {
+	confPath, err := s.getServiceFilePath()
+	if err != nil {
+		return nil, err
+	}
+	path, err := s.execPath()
+	if err != nil {
+		return nil, err
+	}
+
+	args := s.Config.Arguments
+	cmd := strings.Split(path, " ")
+	if len(cmd) > 1 {
+		path = cmd[0]
+		args = append(cmd[1:], args...)
+	}
+	if filepath.Base(path) == path { //check IsAbs
+		if newpath, err := exec.LookPath(path); err == nil {
+			path = newpath
+		}
+	}
+
+	var to = &struct {
+		*Config
+		Path      string
+		Arguments []string
+
+		KeepAlive, RunAtLoad bool
+		SessionCreate        bool
+		StandardOutPath      string
+		StandardErrorPath    string
+
+		KeepAliveOnFailure bool
+		ThrottleInterval   int
+	}{
+		Config:            s.Config,
+		Path:              path,
+		Arguments:         args,
+		KeepAlive:         s.Option.bool(optionKeepAlive, optionKeepAliveDefault),
+		RunAtLoad:         s.Option.bool(optionRunAtLoad, optionRunAtLoadDefault),
+		SessionCreate:     s.Option.bool(optionSessionCreate, optionSessionCreateDefault),
+		StandardOutPath:   s.Option.string(optionStandardOutPath, ""),
+		StandardErrorPath: s.Option.string(optionStandardErrorPath, ""),
+		ThrottleInterval:  seconds(s.RestartPolicy.Delay),
+	}
+	// RestartPolicy.Mode replaces the KeepAlive option.
+	switch s.RestartPolicy.Mode {
+	case RestartAlways:
+		to.KeepAlive = true
+	case RestartOnFailure:
+		to.KeepAliveOnFailure = true
+	case RestartNever:
+		to.KeepAlive = false
+	}
+
+	functions := template.FuncMap{
+		"bool": func(v bool) string {
+			if v {
+				return "true"
+			}
+			return "false"
+		},
+	}
+	t := template.Must(template.New("launchdConfig").Funcs(functions).Parse(launchdConfig))
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, to); err != nil {
+		return nil, err
+	}
+	return &Manifest{
+		Files: []ManifestFile{{Path: confPath, Mode: 0644, Content: buf.Bytes()}},
+	}, nil
+}
+
+var launchdConfig = `<?xml version='1.0' encoding='UTF-8'?>
+<!DOCTYPE plist PUBLIC "-//Apple Computer//DTD PLIST 1.0//EN"
+"http://www.apple.com/DTDs/PropertyList-1.0.dtd" >
+<plist version='1.0'>
+<dict>
+<key>Label</key><string>{{html .Name}}</string>
+    <key>EnvironmentVariables</key>
+    <dict>
+{{ range $key, $value := .Config.Envs }}<key>{{ $key }}</key>
+        <string>{{ $value }}</string>
+{{ end }}
+    </dict>
+
+<key>ProgramArguments</key>
+<array>
+        <string>{{html .Path}}</string>
+{{range .Arguments}}
+        <string>{{html .}}</string>
+{{end}}
+</array>
+{{if .UserName}}<key>UserName</key><string>{{html .UserName}}</string>{{end}}
+{{if .ChRoot}}<key>RootDirectory</key><string>{{html .ChRoot}}</string>{{end}}
+{{if .WorkingDirectory}}<key>WorkingDirectory</key><string>{{html .WorkingDirectory}}</string>{{end}}
+{{if .StandardOutPath}}<key>StandardOutPath</key><string>{{html .StandardOutPath}}</string>{{end}}
+{{if .StandardErrorPath}}<key>StandardErrorPath</key><string>{{html .StandardErrorPath}}</string>{{end}}
+<key>SessionCreate</key><{{bool .SessionCreate}}/>
+{{if .KeepAliveOnFailure}}<key>KeepAlive</key><dict><key>SuccessfulExit</key><false/></dict>{{else}}<key>KeepAlive</key><{{bool .KeepAlive}}/>{{end}}
+{{if .ThrottleInterval}}<key>ThrottleInterval</key><integer>{{.ThrottleInterval}}</integer>
+{{end}}<key>RunAtLoad</key><{{bool .RunAtLoad}}/>
+<key>Disabled</key><false/>
+</dict>
+</plist>
+`
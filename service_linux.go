@@ -5,7 +5,9 @@
 package service
 
 import (
+	"io/ioutil"
 	"os"
+	"strconv"
 	"strings"
 )
 
@@ -14,6 +16,7 @@ type linuxSystemService struct {
 	detect      func() bool
 	interactive func() bool
 	new         func(i Interface, c *Config) (Service, error)
+	validate    func(c *Config) []error
 }
 
 func (sc linuxSystemService) String() string {
@@ -28,58 +31,92 @@ func (sc linuxSystemService) Interactive() bool {
 func (sc linuxSystemService) New(i Interface, c *Config) (Service, error) {
 	return sc.new(i, c)
 }
+func (sc linuxSystemService) validateConfig(c *Config) []error {
+	if sc.validate == nil {
+		return nil
+	}
+	return sc.validate(c)
+}
 
 func init() {
 	ChooseSystem(
 		linuxSystemService{
-			name:   "linux-systemd",
+			name:   systemdName,
 			detect: isSystemd,
 			interactive: func() bool {
 				is, _ := isInteractive()
 				return is
 			},
-			new: newSystemdService,
+			new:      newSystemdService,
+			validate: validateSystemdConfig,
+		},
+		linuxSystemService{
+			name:   openrcName,
+			detect: isOpenRC,
+			interactive: func() bool {
+				is, _ := isInteractive()
+				return is
+			},
+			new:      newOpenRCService,
+			validate: validateOpenRCConfig,
 		},
 		linuxSystemService{
-			name:   "linux-upstart",
+			name:   upstartName,
 			detect: isUpstart,
 			interactive: func() bool {
 				is, _ := isInteractive()
 				return is
 			},
-			new: newUpstartService,
+			new:      newUpstartService,
+			validate: validateUpstartConfig,
 		},
 		linuxSystemService{
-			name:   "linux-procd",
+			name:   procdName,
 			detect: isProcd,
 			interactive: func() bool {
 				is, _ := isInteractive()
 				return is
 			},
-			new: newProcdService,
+			new:      newProcdService,
+			validate: validateProcdConfig,
 		},
 		linuxSystemService{
-			name:   "unix-systemv",
+			name:   runitName,
+			detect: isRunit,
+			interactive: func() bool {
+				is, _ := isInteractive()
+				return is
+			},
+			new:      newRunitService,
+			validate: validateRunitConfig,
+		},
+		linuxSystemService{
+			name:   sysvName,
 			detect: func() bool { return true },
 			interactive: func() bool {
 				is, _ := isInteractive()
 				return is
 			},
-			new: newSystemVService,
+			new:      newSystemVService,
+			validate: validateSystemVConfig,
 		},
 	)
 }
 
 func isInteractive() (bool, error) {
-	// TODO: This is not true for user services.
-	return os.Getppid() != 1, nil
-}
-
-var tf = map[string]interface{}{
-	"cmd": func(s string) string {
-		return `"` + strings.Replace(s, `"`, `\"`, -1) + `"`
-	},
-	"cmdEscape": func(s string) string {
-		return strings.Replace(s, " ", `\x20`, -1)
-	},
+	ppid := os.Getppid()
+	if ppid == 1 {
+		return false, nil
+	}
+	// User services are started by the service manager of the user,
+	// "systemd --user", OpenRC services by supervise-daemon and runit
+	// services by runsv, which are not PID 1.
+	comm, err := ioutil.ReadFile("/proc/" + strconv.Itoa(ppid) + "/comm")
+	if err == nil {
+		switch strings.TrimSpace(string(comm)) {
+		case "systemd", "supervise-daemon", "runsv":
+			return false, nil
+		}
+	}
+	return true, nil
 }
@@ -0,0 +1,86 @@
+// Copyright 2015 Daniel Theophanes.
+// Use of this source code is governed by a zlib-style
+// license that can be found in the LICENSE file.
+
+package service
+
+import (
+	"net"
+	"strings"
+)
+
+// Sockets holds the sockets returned by Listeners. Each name is the
+// FileDescriptorName of the socket in the socket unit, which defaults to
+// the unit name.
+type Sockets struct {
+	Listeners     []net.Listener
+	ListenerNames []string
+
+	PacketConns     []net.PacketConn
+	PacketConnNames []string
+}
+
+// Close closes all the sockets.
+func (s *Sockets) Close() error {
+	var first error
+	for _, l := range s.Listeners {
+		if err := l.Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	for _, c := range s.PacketConns {
+		if err := c.Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// Listeners returns the sockets passed to the program by systemd socket
+// activation, in the order of the ListenStream and ListenDatagram options.
+// If no sockets were passed, as when running interactively, it binds the
+// addresses of those options itself. Addresses starting with a slash are
+// unix sockets, a bare port listens on all interfaces.
+//
+// Listeners should be called once, as it removes the LISTEN_* variables
+// from the environment so child processes do not inherit the sockets.
+func Listeners(c *Config) (*Sockets, error) {
+	sockets, err := activationSockets()
+	if err != nil || sockets != nil {
+		return sockets, err
+	}
+
+	sockets = &Sockets{}
+	name := c.Name + ".socket"
+	for _, addr := range c.Option.strings(optionListenStream, nil) {
+		network := "tcp"
+		if strings.HasPrefix(addr, "/") {
+			network = "unix"
+		} else if !strings.Contains(addr, ":") {
+			addr = ":" + addr
+		}
+		l, err := net.Listen(network, addr)
+		if err != nil {
+			sockets.Close()
+			return nil, err
+		}
+		sockets.Listeners = append(sockets.Listeners, l)
+		sockets.ListenerNames = append(sockets.ListenerNames, name)
+	}
+	for _, addr := range c.Option.strings(optionListenDatagram, nil) {
+		network := "udp"
+		if strings.HasPrefix(addr, "/") {
+			network = "unixgram"
+		} else if !strings.Contains(addr, ":") {
+			addr = ":" + addr
+		}
+		pc, err := net.ListenPacket(network, addr)
+		if err != nil {
+			sockets.Close()
+			return nil, err
+		}
+		sockets.PacketConns = append(sockets.PacketConns, pc)
+		sockets.PacketConnNames = append(sockets.PacketConnNames, name)
+	}
+	return sockets, nil
+}
@@ -0,0 +1,80 @@
+// Copyright 2015 Daniel Theophanes.
+// Use of this source code is governed by a zlib-style
+// license that can be found in the LICENSE file.
+
+package service
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// listenFdsStart is the first file descriptor passed by systemd.
+var listenFdsStart = 3
+
+// activationSockets returns the sockets passed by systemd in the LISTEN_FDS
+// protocol, or nil if there are none for this process.
+func activationSockets() (*Sockets, error) {
+	pid, err := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	if err != nil || pid != os.Getpid() {
+		return nil, nil
+	}
+	n, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || n <= 0 {
+		return nil, nil
+	}
+	names := strings.Split(os.Getenv("LISTEN_FDNAMES"), ":")
+	os.Unsetenv("LISTEN_PID")
+	os.Unsetenv("LISTEN_FDS")
+	os.Unsetenv("LISTEN_FDNAMES")
+
+	sockets := &Sockets{}
+	for i := 0; i < n; i++ {
+		fd := listenFdsStart + i
+		syscall.CloseOnExec(fd)
+		name := ""
+		if i < len(names) {
+			name = names[i]
+		}
+		if err := sockets.add(fd, name); err != nil {
+			sockets.Close()
+			return nil, err
+		}
+	}
+	return sockets, nil
+}
+
+// add adds the socket fd to the listeners or packet conns by its type.
+// The fd is closed, the sockets use a copy.
+func (s *Sockets) add(fd int, name string) error {
+	f := os.NewFile(uintptr(fd), name)
+	defer f.Close()
+
+	sotype, err := syscall.GetsockoptInt(fd, syscall.SOL_SOCKET, syscall.SO_TYPE)
+	if err != nil {
+		return fmt.Errorf("File descriptor %d is not a socket: %v", fd, err)
+	}
+	switch sotype {
+	case syscall.SOCK_STREAM, syscall.SOCK_SEQPACKET:
+		l, err := net.FileListener(f)
+		if err != nil {
+			return err
+		}
+		s.Listeners = append(s.Listeners, l)
+		s.ListenerNames = append(s.ListenerNames, name)
+	case syscall.SOCK_DGRAM:
+		pc, err := net.FilePacketConn(f)
+		if err != nil {
+			return err
+		}
+		s.PacketConns = append(s.PacketConns, pc)
+		s.PacketConnNames = append(s.PacketConnNames, name)
+	default:
+		return fmt.Errorf("File descriptor %d has unsupported socket type %d", fd, sotype)
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+// Copyright 2015 Daniel Theophanes.
+// Use of this source code is governed by a zlib-style
+// license that can be found in the LICENSE file.
+
+package service
+
+import (
+	"net"
+	"os"
+	"strconv"
+	"syscall"
+	"testing"
+)
+
+func TestListenersActivation(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+
+	// Place the sockets at consecutive descriptors as systemd would.
+	defer func(start int) { listenFdsStart = start }(listenFdsStart)
+	listenFdsStart = 100
+	for i, s := range []interface {
+		File() (*os.File, error)
+	}{l.(*net.TCPListener), pc.(*net.UDPConn)} {
+		f, err := s.File()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := syscall.Dup3(int(f.Fd()), listenFdsStart+i, 0); err != nil {
+			t.Fatal(err)
+		}
+		f.Close()
+	}
+	os.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid()))
+	os.Setenv("LISTEN_FDS", "2")
+	os.Setenv("LISTEN_FDNAMES", "http:dns")
+
+	sockets, err := Listeners(&Config{Name: "go_service_test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sockets.Close()
+	if len(sockets.Listeners) != 1 || sockets.ListenerNames[0] != "http" {
+		t.Fatalf("Listeners: %v %v", sockets.Listeners, sockets.ListenerNames)
+	}
+	if len(sockets.PacketConns) != 1 || sockets.PacketConnNames[0] != "dns" {
+		t.Fatalf("PacketConns: %v %v", sockets.PacketConns, sockets.PacketConnNames)
+	}
+	if got, want := sockets.Listeners[0].Addr().String(), l.Addr().String(); got != want {
+		t.Errorf("listener address %s, want %s", got, want)
+	}
+	if len(os.Getenv("LISTEN_FDS")) != 0 {
+		t.Error("LISTEN_FDS not removed from the environment")
+	}
+}
+
+func TestListenersFallback(t *testing.T) {
+	c := &Config{
+		Name: "go_service_test",
+		Option: KeyValue{
+			optionListenStream:   []string{"127.0.0.1:0"},
+			optionListenDatagram: []string{"127.0.0.1:0"},
+		},
+	}
+	sockets, err := Listeners(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sockets.Close()
+	if len(sockets.Listeners) != 1 || len(sockets.PacketConns) != 1 {
+		t.Fatalf("Listeners bound %d stream and %d datagram sockets, want 1 and 1", len(sockets.Listeners), len(sockets.PacketConns))
+	}
+	if sockets.ListenerNames[0] != "go_service_test.socket" {
+		t.Errorf("fallback name %q", sockets.ListenerNames[0])
+	}
+}
@@ -0,0 +1,13 @@
+// Copyright 2015 Daniel Theophanes.
+// Use of this source code is governed by a zlib-style
+// license that can be found in the LICENSE file.
+
+// +build !linux
+
+package service
+
+// activationSockets returns nil as socket activation is only supported by
+// systemd.
+func activationSockets() (*Sockets, error) {
+	return nil, nil
+}
@@ -0,0 +1,92 @@
+// Copyright 2015 Daniel Theophanes.
+// Use of this source code is governed by a zlib-style
+// license that can be found in the LICENSE file.
+
+package service
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"time"
+)
+
+// sdNotify sends a state such as "READY=1" to the systemd notification
+// socket named by $NOTIFY_SOCKET. It does nothing if the variable is not
+// set, as when not running under systemd.
+func sdNotify(state string) error {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if len(socket) == 0 {
+		return nil
+	}
+	// A leading @ names an abstract socket, which net handles.
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	_, err = conn.Write([]byte(state))
+	return err
+}
+
+// notify sends state to systemd, logging any error as the program keeps
+// running regardless.
+func (s *systemd) notify(state string) {
+	if err := sdNotify(state); err != nil {
+		if l, lerr := s.Logger(nil); lerr == nil {
+			l.Errorf("Failed to notify systemd of %s: %v", state, err)
+		}
+	}
+}
+
+func (s *systemd) Notify(state string) error {
+	return sdNotify(state)
+}
+
+func (s *systemd) SetStatus(status string) error {
+	return sdNotify("STATUS=" + status)
+}
+
+func (s *systemd) ExtendTimeout(d time.Duration) error {
+	return sdNotify(fmt.Sprintf("EXTEND_TIMEOUT_USEC=%d", d/time.Microsecond))
+}
+
+// watchdogInterval returns how often to ping the systemd watchdog, half of
+// $WATCHDOG_USEC, or zero if the watchdog is not enabled for this process.
+func watchdogInterval() time.Duration {
+	if pid := os.Getenv("WATCHDOG_PID"); len(pid) > 0 && pid != strconv.Itoa(os.Getpid()) {
+		return 0
+	}
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 {
+		return 0
+	}
+	return time.Duration(usec) * time.Microsecond / 2
+}
+
+// watchdog pings the systemd watchdog every interval until stop is closed.
+// If the program implements HealthChecker and is not healthy the ping is
+// skipped, so systemd restarts the service if it does not recover. Failed
+// checks are logged to l unless it is nil.
+func (s *systemd) watchdog(interval time.Duration, stop <-chan struct{}, l Logger) {
+	checker, _ := s.i.(HealthChecker)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+		if checker != nil {
+			if err := checker.CheckHealth(s); err != nil {
+				if l != nil {
+					l.Warningf("Health check failed, not pinging the watchdog: %v", err)
+				}
+				continue
+			}
+		}
+		s.notify("WATCHDOG=1")
+	}
+}
@@ -0,0 +1,159 @@
+// Copyright 2015 Daniel Theophanes.
+// Use of this source code is governed by a zlib-style
+// license that can be found in the LICENSE file.
+
+package service
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+type notifyProgram struct{}
+
+func (p *notifyProgram) Start(s Service) error {
+	return s.(Notifier).SetStatus("Starting")
+}
+func (p *notifyProgram) Stop(s Service) error {
+	return s.(Notifier).ExtendTimeout(2 * time.Second)
+}
+
+type healthProgram struct {
+	notifyProgram
+	healthy bool
+}
+
+func (p *healthProgram) CheckHealth(s Service) error {
+	if !p.healthy {
+		return errors.New("unhealthy")
+	}
+	return nil
+}
+
+// recordLogger keeps the warnings logged to it.
+type recordLogger struct {
+	mu       sync.Mutex
+	warnings []string
+}
+
+func (l *recordLogger) Error(v ...interface{}) error   { return nil }
+func (l *recordLogger) Warning(v ...interface{}) error { return l.Warningf("%s", fmt.Sprint(v...)) }
+func (l *recordLogger) Info(v ...interface{}) error    { return nil }
+
+func (l *recordLogger) Errorf(format string, a ...interface{}) error { return nil }
+func (l *recordLogger) Warningf(format string, a ...interface{}) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.warnings = append(l.warnings, fmt.Sprintf(format, a...))
+	return nil
+}
+func (l *recordLogger) Infof(format string, a ...interface{}) error { return nil }
+
+func (l *recordLogger) Warnings() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]string(nil), l.warnings...)
+}
+
+// listenNotify sets $NOTIFY_SOCKET to a new socket and returns it with a
+// function to clean up.
+func listenNotify(t *testing.T) (*net.UnixConn, func()) {
+	dir, err := ioutil.TempDir("", "go_service_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	socket := filepath.Join(dir, "notify")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	os.Setenv("NOTIFY_SOCKET", socket)
+	return conn, func() {
+		os.Unsetenv("NOTIFY_SOCKET")
+		conn.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func TestNotify(t *testing.T) {
+	conn, cleanup := listenNotify(t)
+	defer cleanup()
+
+	c := &Config{
+		Name:   "go_service_test",
+		Option: KeyValue{optionRunWait: func() {}},
+	}
+	s, err := newSystemdService(&notifyProgram{}, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"STATUS=Starting", "READY=1", "STOPPING=1", "EXTEND_TIMEOUT_USEC=2000000"}
+	buf := make([]byte, 256)
+	for _, w := range want {
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		n, err := conn.Read(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := string(buf[:n]); got != w {
+			t.Errorf("notification %q, want %q", got, w)
+		}
+	}
+}
+
+func TestWatchdog(t *testing.T) {
+	os.Setenv("WATCHDOG_USEC", "20000")
+	defer os.Unsetenv("WATCHDOG_USEC")
+
+	interval := watchdogInterval()
+	if interval != 10*time.Millisecond {
+		t.Fatalf("watchdogInterval: %v, want 10ms", interval)
+	}
+	buf := make([]byte, 256)
+	for _, healthy := range []bool{true, false} {
+		conn, cleanup := listenNotify(t)
+		p := &healthProgram{healthy: healthy}
+		s, err := newSystemdService(p, &Config{Name: "go_service_test"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		stop := make(chan struct{})
+		done := make(chan struct{})
+		l := &recordLogger{}
+		go func() {
+			s.(*systemd).watchdog(interval, stop, l)
+			close(done)
+		}()
+
+		conn.SetReadDeadline(time.Now().Add(10 * interval))
+		n, err := conn.Read(buf)
+		close(stop)
+		<-done
+		cleanup()
+		if healthy && (err != nil || string(buf[:n]) != "WATCHDOG=1") {
+			t.Errorf("healthy watchdog: %q, %v", buf[:n], err)
+		}
+		if !healthy && err == nil {
+			t.Errorf("unhealthy watchdog pinged: %q", buf[:n])
+		}
+		warnings := l.Warnings()
+		if healthy && len(warnings) != 0 {
+			t.Errorf("healthy watchdog warned: %q", warnings)
+		}
+		if !healthy && (len(warnings) == 0 || warnings[0] != "Health check failed, not pinging the watchdog: unhealthy") {
+			t.Errorf("unhealthy watchdog warnings: %q", warnings)
+		}
+	}
+}
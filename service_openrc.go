@@ -0,0 +1,201 @@
+// Copyright 2015 Daniel Theophanes.
+// Use of this source code is governed by a zlib-style
+// license that can be found in the LICENSE file.
+
+package service
+
+import (
+	"bytes"
+	"strings"
+	"text/template"
+)
+
+// openrc runs the service from an openrc-run script, supervised by
+// supervise-daemon, as on Alpine and Gentoo.
+type openrc struct {
+	i Interface
+	*Config
+}
+
+func (s *openrc) configPath() string {
+	return "/etc/init.d/" + s.Name
+}
+
+// pidFile returns the file holding the PID of the program: the value
+// supervise-daemon keeps for the service, or the pidfile written by
+// start-stop-daemon for services that are not restarted.
+func (s *openrc) pidFile() string {
+	if s.supervised() {
+		return "/run/openrc/options/" + s.Name + "/child_pid"
+	}
+	return s.Option.string(optionPIDFile, "/run/"+s.Name+".pid")
+}
+
+// supervised reports if supervise-daemon restarts the program, it restarts
+// it whatever the exit status.
+func (s *openrc) supervised() bool {
+	return s.restartPolicyOr(RestartPolicy{Mode: RestartAlways}).Mode != RestartNever
+}
+
+// openrcFacilities maps the LSB init script facilities to the OpenRC
+// services providing them.
+var openrcFacilities = map[string]string{
+	"$local_fs":  "localmount",
+	"$network":   "net",
+	"$portmap":   "rpcbind",
+	"$remote_fs": "netmount",
+	"$syslog":    "logger",
+}
+
+// openrcNames returns the OpenRC services of the dependencies of the given
+// kinds. systemd targets and facilities OpenRC has no service for are left
+// out.
+func (s *openrc) openrcNames(kinds ...dependencyKind) string {
+	var names []string
+	for _, name := range s.dependencyNames(kinds...) {
+		name = lsbName(name)
+		if service, found := openrcFacilities[name]; found {
+			names = append(names, service)
+		} else if job := jobName(name); len(job) > 0 {
+			names = append(names, job)
+		}
+	}
+	return strings.Join(names, " ")
+}
+
+// openrcArgs joins the arguments for command_args, which openrc-run reads
+// with eval from a double quoted value.
+func openrcArgs(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = shquote(arg)
+	}
+	return openrcArgsReplacer.Replace(strings.Join(quoted, " "))
+}
+
+var openrcArgsReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "$", `\$`, "`", "\\`")
+
+func (s *openrc) template() *template.Template {
+	return template.Must(template.New("").Funcs(tf).Parse(openrcScript))
+}
+
+// Render returns the openrc-run script created by Install and the command
+// adding it to the default runlevel.
+func (s *openrc) Render() (*Manifest, error) {
+	path, err := s.execPath()
+	if err != nil {
+		return nil, err
+	}
+	args := s.Arguments
+	if cmd := strings.Split(path, " "); len(cmd) > 1 {
+		path = cmd[0]
+		args = append(cmd[1:], args...)
+	}
+
+	// supervise-daemon keeps its own defaults for the zero fields.
+	restart := s.restartPolicyOr(RestartPolicy{Mode: RestartAlways})
+
+	// Only setting StandardOutPath sends the errors there as well.
+	stdout := s.Option.string(optionStandardOutPath, "")
+	stderr := s.Option.string(optionStandardErrorPath, stdout)
+
+	var to = &struct {
+		*Config
+		Path         string
+		Args         string
+		StopTimeout  int
+		ReloadSignal string
+
+		Supervised    bool
+		PIDFile       string
+		RespawnDelay  int
+		RespawnMax    int
+		RespawnPeriod int
+
+		StdoutLog string
+		StderrLog string
+
+		Need  string
+		Want  string
+		After string
+	}{
+		s.Config,
+		path,
+		openrcArgs(args),
+		s.timeoutSeconds(optionStopTimeout, optionStopTimeoutDefault),
+		s.reloadSignalName(s.i),
+
+		s.supervised(),
+		s.pidFile(),
+		seconds(restart.Delay),
+		restart.Burst,
+		seconds(restart.Window),
+
+		stdout,
+		stderr,
+
+		s.openrcNames(dependRequires),
+		s.openrcNames(dependWants),
+		s.openrcNames(dependAfter),
+	}
+
+	var buf bytes.Buffer
+	if err := s.template().Execute(&buf, to); err != nil {
+		return nil, err
+	}
+	return &Manifest{
+		Files:    []ManifestFile{{Path: s.configPath(), Mode: 0755, Content: buf.Bytes()}},
+		Commands: []Command{{Name: "rc-update", Args: []string{"add", s.Name, "default"}}},
+	}, nil
+}
+
+const openrcScript = `#!/sbin/openrc-run
+# {{.Description}}
+
+name={{if .DisplayName}}{{.DisplayName|shquote}}{{else}}{{.Name|shquote}}{{end}}
+description={{.Description|shquote}}
+
+{{if .Supervised}}supervisor=supervise-daemon
+{{if .RespawnDelay}}respawn_delay={{.RespawnDelay}}
+{{end}}{{if .RespawnMax}}respawn_max={{.RespawnMax}}
+{{end}}{{if .RespawnPeriod}}respawn_period={{.RespawnPeriod}}
+{{end}}{{else}}command_background=yes
+pidfile={{.PIDFile|shquote}}
+{{end}}command={{.Path|shquote}}
+command_args="{{.Args}}"
+{{if .UserName}}command_user={{.UserName|shquote}}
+{{end}}{{if .WorkingDirectory}}directory={{.WorkingDirectory|shquote}}
+{{end}}{{if .ChRoot}}chroot={{.ChRoot|shquote}}
+{{end}}{{if .StdoutLog}}output_log={{.StdoutLog|shquote}}
+{{end}}{{if .StderrLog}}error_log={{.StderrLog|shquote}}
+{{end}}{{if .StopTimeout}}retry="TERM/{{.StopTimeout}}/KILL/5"
+{{end}}
+{{range $k, $v := .Envs}}export {{$k}}={{$v | shquote}}
+{{end}}
+depend() {
+    use logger
+{{- if .Need}}
+    need {{.Need}}
+{{- end}}
+{{- if .Want}}
+    want {{.Want}}
+{{- end}}
+{{- if .After}}
+    after {{.After}}
+{{- end}}
+}
+{{- if .ReloadSignal}}
+
+extra_started_commands="reload"
+
+reload() {
+    ebegin "Reloading ${RC_SVCNAME}"
+{{- if .Supervised}}
+    supervise-daemon "${RC_SVCNAME}" --signal {{.ReloadSignal}}
+{{- else}}
+    start-stop-daemon --signal {{.ReloadSignal}} --pidfile "${pidfile}"
+{{- end}}
+    eend $?
+}
+{{- end}}
+`
@@ -0,0 +1,172 @@
+// Copyright 2015 Daniel Theophanes.
+// Use of this source code is governed by a zlib-style
+// license that can be found in the LICENSE file.
+
+package service
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"syscall"
+)
+
+func isOpenRC() bool {
+	if _, err := os.Stat("/sbin/openrc-run"); err == nil {
+		return true
+	}
+	return false
+}
+
+func newOpenRCService(i Interface, c *Config) (Service, error) {
+	s := &openrc{
+		i:      i,
+		Config: c,
+	}
+
+	return s, nil
+}
+
+func (s *openrc) String() string {
+	if len(s.DisplayName) > 0 {
+		return s.DisplayName
+	}
+	return s.Name
+}
+
+// validateOpenRCConfig rejects schedules, the crond of Alpine does not read
+// /etc/cron.d.
+func validateOpenRCConfig(c *Config) []error {
+	var errs []error
+	if c.Option.bool(optionUserService, optionUserServiceDefault) {
+		errs = append(errs, errUnsupported("UserService", openrcName))
+	}
+	if c.scheduled() {
+		errs = append(errs, errUnsupported("Config.Schedule", openrcName))
+	}
+	return errs
+}
+
+func (s *openrc) Install() error {
+	_, err := s.install()
+	return err
+}
+
+func (s *openrc) install() (*Manifest, error) {
+	if err := s.Validate(system); err != nil {
+		return nil, err
+	}
+	confPath := s.configPath()
+	_, err := os.Stat(filepath.Join(s.installRoot(), confPath))
+	if err == nil {
+		return nil, fmt.Errorf("Init already exists: %s", confPath)
+	}
+
+	m, err := s.Render()
+	if err != nil {
+		return nil, err
+	}
+	return s.apply(m)
+}
+
+// Uninstall stops the service, removes it from the default runlevel and
+// removes the script.
+func (s *openrc) Uninstall() error {
+	s.run("rc-service", s.Name, "stop")
+	s.run("rc-update", "del", s.Name, "default")
+	return os.Remove(s.configPath())
+}
+
+func (s *openrc) Logger(errs chan<- error) (Logger, error) {
+	if system.Interactive() {
+		return ConsoleLogger, nil
+	}
+	return s.SystemLogger(errs)
+}
+
+func (s *openrc) SystemLogger(errs chan<- error) (Logger, error) {
+	return newSysLogger(s.Name, errs)
+}
+
+func (s *openrc) Run() (err error) {
+	err = s.startProgram(s.i, s)
+	if err != nil {
+		return err
+	}
+
+	s.Option.funcSingle(optionRunWait, func() {
+		s.waitForSignal(s.i, s, syscall.SIGTERM, os.Interrupt)
+	})()
+
+	return s.stopProgram(s.i, s)
+}
+
+func (s *openrc) Start() error {
+	return s.run("rc-service", s.Name, "start")
+}
+
+func (s *openrc) Stop() error {
+	return s.run("rc-service", s.Name, "stop")
+}
+
+func (s *openrc) Restart() error {
+	return s.run("rc-service", s.Name, "restart")
+}
+
+func (s *openrc) Reload() error {
+	return s.run("rc-service", s.Name, "reload")
+}
+
+// Update rewrites the installed files that differ from what Install would
+// write. See InstallOrUpdate.
+func (s *openrc) Update() error {
+	return updateService(s)
+}
+
+// checkRunning reads the PID of the program kept by supervise-daemon or
+// start-stop-daemon.
+func (s *openrc) checkRunning() (int, error) {
+	return readPIDFile(s.pidFile())
+}
+
+func (s *openrc) PID() (int, error) {
+	return s.checkRunning()
+}
+
+var openrcStatusRe = regexp.MustCompile(`status: ([a-z]+)`)
+
+// Status - Get service status
+func (s *openrc) Status() (ServiceStatus, error) {
+	status := ServiceStatus{Backend: openrcName}
+	if _, err := os.Stat(s.configPath()); os.IsNotExist(err) {
+		status.State = StateNotInstalled
+		return status, nil
+	}
+
+	// rc-service fails unless the service is started, the output tells the
+	// state either way.
+	output, err := s.runWithOutput("rc-service", s.Name, "status")
+	if err != nil {
+		if _, ok := exitStatus(err); !ok {
+			return status, err
+		}
+	}
+	data := openrcStatusRe.FindStringSubmatch(string(output))
+	switch {
+	case data == nil:
+		status.State = StateUnknown
+	case data[1] == "started":
+		status.State = StateRunning
+		if pid, err := s.checkRunning(); err == nil {
+			status.PID = pid
+		}
+	case data[1] == "crashed":
+		status.State = StateFailed
+	case data[1] == "starting":
+		status.State = StateActivating
+	default:
+		status.State = StateStopped
+	}
+	return status, nil
+}
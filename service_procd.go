@@ -0,0 +1,189 @@
+// Copyright 2015 Daniel Theophanes.
+// Use of this source code is governed by a zlib-style
+// license that can be found in the LICENSE file.
+
+package service
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// procd - standard record (struct) for linux procd version of daemon package
+type procd struct {
+	i Interface
+	*Config
+
+	// offline renders the default priorities without reading the init
+	// scripts of the host.
+	offline bool
+}
+
+// Standard service path for procd daemons
+func (u *procd) servicePath() string {
+	return "/etc/init.d/" + u.Name
+}
+
+var (
+	procdStartRe = regexp.MustCompile(`(?m)^START=([0-9]+)`)
+	procdStopRe  = regexp.MustCompile(`(?m)^STOP=([0-9]+)`)
+)
+
+// priorities returns the START and STOP priorities of the init script. The
+// service starts after and stops before the dependencies found in
+// /etc/init.d, procd does not tell hard from soft dependencies.
+func (u *procd) priorities() (start, stop int) {
+	start, stop = 120, 120
+	if u.offline {
+		return start, stop
+	}
+	for _, name := range u.dependencyNames(dependRequires, dependWants, dependAfter) {
+		job := jobName(name)
+		if len(job) == 0 {
+			continue
+		}
+		script, err := ioutil.ReadFile("/etc/init.d/" + job)
+		if err != nil {
+			continue
+		}
+		if m := procdStartRe.FindSubmatch(script); m != nil {
+			if n, _ := strconv.Atoi(string(m[1])); n >= start {
+				start = n + 1
+			}
+		}
+		if m := procdStopRe.FindSubmatch(script); m != nil {
+			if n, _ := strconv.Atoi(string(m[1])); n > 0 && n <= stop {
+				stop = n - 1
+			}
+		}
+	}
+	return start, stop
+}
+
+// procdCommand returns the words of procd_set_param command, quoted for the
+// init script. procd has no working directory parameter, a shell changes to
+// dir before running the program.
+func procdCommand(dir, path string, args []string) string {
+	if cmd := strings.Split(path, " "); len(cmd) > 1 {
+		path = cmd[0]
+		args = append(cmd[1:], args...)
+	}
+	words := []string{shquote(path)}
+	if len(dir) > 0 {
+		words = []string{"/bin/sh", "-c", shquote("cd " + shquote(dir) + ` && exec "$0" "$@"`), shquote(path)}
+	}
+	for _, arg := range args {
+		words = append(words, shquote(arg))
+	}
+	return strings.Join(words, " ")
+}
+
+// Render returns the init script and the enable command run by Install.
+func (u *procd) Render() (*Manifest, error) {
+	path, err := u.execPath()
+	if err != nil {
+		return nil, err
+	}
+	templ, err := template.New("procdConfig").Funcs(template.FuncMap{"StringsJoin": strings.Join}).Parse(procdConfig)
+	if err != nil {
+		return nil, err
+	}
+	envs := make([]string, 0, len(u.Config.Envs))
+	if len(u.Config.Envs) > 0 {
+		for k, v := range u.Config.Envs {
+			envs = append(envs, k+"="+shquote(v))
+		}
+		sort.Strings(envs)
+	}
+
+	// The procd defaults, it restarts on failure and success alike.
+	restart := u.restartPolicyOr(RestartPolicy{
+		Mode:   RestartAlways,
+		Delay:  5 * time.Second,
+		Burst:  5,
+		Window: time.Hour,
+	})
+	respawn := ""
+	if restart.Mode != RestartNever {
+		respawn = fmt.Sprintf("%d %d %d", seconds(restart.Window), seconds(restart.Delay), restart.Burst)
+	}
+
+	start, stop := u.priorities()
+	user := strings.SplitN(u.UserName, ":", 2)
+	if len(user) == 1 {
+		user = append(user, "")
+	}
+
+	var buf bytes.Buffer
+	if err := templ.Execute(
+		&buf,
+		&struct {
+			Name, Description string
+			User, Group       string
+			Cmd               string
+			Envs              []string
+			StopTimeout       int
+			ReloadSignal      string
+			Respawn           string
+			Start, Stop       int
+		}{
+			Name:         u.Name,
+			Cmd:          procdCommand(u.WorkingDirectory, path, u.Arguments),
+			Description:  u.Description,
+			User:         user[0],
+			Group:        user[1],
+			Envs:         envs,
+			StopTimeout:  u.timeoutSeconds(optionStopTimeout, optionStopTimeoutDefault),
+			ReloadSignal: u.reloadSignalName(u.i),
+			Respawn:      respawn,
+			Start:        start,
+			Stop:         stop,
+		},
+	); err != nil {
+		return nil, err
+	}
+
+	return &Manifest{
+		Files:    []ManifestFile{{Path: u.servicePath(), Mode: 0755, Content: buf.Bytes()}},
+		Commands: []Command{{Name: u.servicePath(), Args: []string{"enable"}}},
+	}, nil
+}
+
+var procdConfig = `#!/bin/sh /etc/rc.common
+
+# {{.Name}} {{.Description}}
+USE_PROCD=1
+START={{.Start}}
+STOP={{.Stop}}
+
+start_service() {
+  procd_open_instance
+  procd_set_param command {{.Cmd}}
+{{if .User}}  procd_set_param user {{.User}}
+{{end}}{{if .Group}}  procd_set_param group {{.Group}}
+{{end}}
+  # respawn automatically if something died, be careful if you have an alternative process supervisor
+  # if process dies sooner than respawn_threshold, it is considered crashed and after 5 retries the service is stopped
+{{if .Respawn}}  procd_set_param respawn {{.Respawn}}{{end}}
+  procd_set_param limits core="unlimited"
+  procd_set_param stdout 1
+  procd_set_param stderr 1
+  procd_set_param pidfile /var/run/{{.Name}}.pid
+{{if .StopTimeout}}  procd_set_param term_timeout {{.StopTimeout}}{{end}}
+{{if .ReloadSignal}}  procd_set_param reload_signal {{.ReloadSignal}}{{end}}
+
+{{if len .Envs}}
+  procd_set_param env \
+  {{ StringsJoin .Envs "\\\n " }}
+{{end}}
+
+  procd_close_instance
+}
+`
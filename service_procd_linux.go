@@ -4,12 +4,10 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
-	"os/exec"
-	"os/signal"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"syscall"
-	"text/template"
 )
 
 func isProcd() bool {
@@ -35,15 +33,21 @@ func (u *procd) String() string {
 	return u.Name
 }
 
-// procd - standard record (struct) for linux procd version of daemon package
-type procd struct {
-	i Interface
-	*Config
-}
-
-// Standard service path for procd daemons
-func (u *procd) servicePath() string {
-	return "/etc/init.d/" + u.Name
+// validateProcdConfig rejects user services and ChRoot, which procd has no
+// parameter for, and schedules, as the busybox crond of OpenWrt does not read
+// /etc/cron.d.
+func validateProcdConfig(c *Config) []error {
+	var errs []error
+	if c.Option.bool(optionUserService, optionUserServiceDefault) {
+		errs = append(errs, errUnsupported("UserService", procdName))
+	}
+	if len(c.ChRoot) != 0 {
+		errs = append(errs, errUnsupported("Config.ChRoot", procdName))
+	}
+	if c.scheduled() {
+		errs = append(errs, errUnsupported("Config.Schedule", procdName))
+	}
+	return errs
 }
 
 // Is a service installed
@@ -83,90 +87,59 @@ func (u *procd) checkRunning() (int, error) {
 
 // Install the service
 func (u *procd) Install() error {
-	srvPath := u.servicePath()
+	_, err := u.install()
+	return err
+}
 
-	if u.IsInstalled() {
-		u.Uninstall()
+func (u *procd) install() (*Manifest, error) {
+	if err := u.Validate(system); err != nil {
+		return nil, err
 	}
-
-	file, err := os.Create(srvPath)
-	if err != nil {
-		return err
+	_, err := os.Stat(filepath.Join(u.installRoot(), u.servicePath()))
+	if err == nil {
+		return nil, fmt.Errorf("Init already exists: %s", u.servicePath())
 	}
-	defer file.Close()
 
-	templ, err := template.New("procdConfig").Funcs(template.FuncMap{"StringsJoin": strings.Join}).Parse(procdConfig)
+	m, err := u.Render()
 	if err != nil {
-		return err
+		return nil, err
 	}
-	envs := make([]string, 0, len(u.Config.Envs))
-	if len(u.Config.Envs) > 0 {
-		for k, v := range u.Config.Envs {
-			envs = append(envs, fmt.Sprintf(`%s="%s"`, k, v))
-		}
-	}
-
-	if err := templ.Execute(
-		file,
-		&struct {
-			Name, Description string
-			Args, WorkingDir  string
-			Cmd               string
-			Envs              []string
-		}{
-			Name:        u.Name,
-			Cmd:         u.Executable,
-			Description: u.Description,
-			WorkingDir:  u.WorkingDirectory,
-			Envs:        envs,
-		},
-	); err != nil {
-		return err
-	}
-
-	if err := os.Chmod(srvPath, 0755); err != nil {
-		return err
-	}
-	file.Close()
-
-	run(u.servicePath(), "enable")
-	return nil
+	return u.apply(m)
 }
 
 // Uninstall removes the service
 func (u *procd) Uninstall() error {
 	u.Stop()
 
-	run(u.servicePath(), "disable")
+	u.run(u.servicePath(), "disable")
 
 	return os.Remove(u.servicePath())
 }
 
 func (u *procd) Run() (err error) {
-	err = u.i.Start(u)
+	err = u.startProgram(u.i, u)
 	if err != nil {
 		return err
 	}
 
 	u.Option.funcSingle(optionRunWait, func() {
-		var sigChan = make(chan os.Signal, 3)
-		signal.Notify(sigChan, syscall.SIGTERM, os.Interrupt)
-		<-sigChan
+		u.waitForSignal(u.i, u, syscall.SIGTERM, os.Interrupt)
 	})()
 
-	return u.i.Stop(u)
+	return u.stopProgram(u.i, u)
 }
 
 func (u *procd) Restart() error {
-	if err := exec.Command(u.servicePath(), "restart").Run(); err != nil {
+	if err := u.run(u.servicePath(), "restart"); err != nil {
 		return err
 	}
 	return nil
 }
 
+// Update rewrites the installed files that differ from what Install would
+// write. See InstallOrUpdate.
 func (u *procd) Update() error {
-	u.Uninstall()
-	return u.Install()
+	return updateService(u)
 }
 
 func (u *procd) PID() (int, error) {
@@ -189,7 +162,7 @@ func (u *procd) Start() error {
 	if !u.IsInstalled() {
 		return ErrServiceIsNotInstalled
 	}
-	if err := exec.Command(u.servicePath(), "start").Run(); err != nil {
+	if err := u.run(u.servicePath(), "start"); err != nil {
 		return err
 	}
 	return nil
@@ -200,45 +173,36 @@ func (u *procd) Stop() error {
 	if !u.IsInstalled() {
 		return ErrServiceIsNotInstalled
 	}
-	if err := exec.Command(u.servicePath(), "stop").Run(); err != nil {
+	if err := u.run(u.servicePath(), "stop"); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Reload the service
+func (u *procd) Reload() error {
+	if !u.IsInstalled() {
+		return ErrServiceIsNotInstalled
+	}
+	if err := u.run(u.servicePath(), "reload"); err != nil {
 		return err
 	}
 	return nil
 }
 
 // Status - Get service status
-func (u *procd) Status() (string, error) {
+func (u *procd) Status() (ServiceStatus, error) {
+	status := ServiceStatus{Backend: procdName}
+	if !u.IsInstalled() {
+		status.State = StateNotInstalled
+		return status, nil
+	}
 	pid, err := u.checkRunning()
 	if err != nil {
-		return "", err
+		status.State = StateStopped
+		return status, nil
 	}
-	return "(pid: " + strconv.Itoa(pid) + ")", nil
-}
-
-var procdConfig = `#!/bin/sh /etc/rc.common
-
-# {{.Name}} {{.Description}}
-USE_PROCD=1
-START=120
-STOP=120
-
-start_service() {
-  procd_open_instance
-  procd_set_param command {{.Cmd}} {{.Args}}
-
-  # respawn automatically if something died, be careful if you have an alternative process supervisor
-  # if process dies sooner than respawn_threshold, it is considered crashed and after 5 retries the service is stopped
-  procd_set_param respawn
-  procd_set_param limits core="unlimited"
-  procd_set_param stdout 1
-  procd_set_param stderr 1
-  procd_set_param pidfile /var/run/{{.Name}}.pid
-
-{{if len .Envs}}
-  procd_set_param env \
-  {{ StringsJoin .Envs "\\\n " }}
-{{end}}
-
-  procd_close_instance
+	status.State = StateRunning
+	status.PID = pid
+	return status, nil
 }
-`
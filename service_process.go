@@ -3,7 +3,9 @@ package service
 import (
 	"fmt"
 	"github.com/isaaxiot/service/process"
+	"github.com/isaaxiot/service/process/signals"
 	"strconv"
+	"strings"
 )
 
 var Supervise = false
@@ -12,6 +14,10 @@ func init() {
 	ChooseSystem(supervisedSystem{})
 }
 
+// supervisedProcesses holds the programs of all supervised services, so a
+// service can start the services it depends on.
+var supervisedProcesses = process.NewProcessManager()
+
 type supervisedService struct {
 	i Interface
 	*Config
@@ -30,11 +36,31 @@ func (supervisedSystem) Interactive() bool {
 	return false
 }
 
+func (sc supervisedSystem) validateConfig(c *Config) []error {
+	var errs []error
+	if len(c.Executable) == 0 {
+		errs = append(errs, fmt.Errorf("Config.Executable is required on %s.", sc))
+	}
+	if len(c.ChRoot) != 0 {
+		errs = append(errs, errUnsupported("Config.ChRoot", sc.String()))
+	}
+	if len(c.Schedule) != 0 {
+		errs = append(errs, errUnsupported("Config.Schedule", sc.String()))
+	}
+	if c.RestartPolicy.Delay != 0 {
+		errs = append(errs, errUnsupported("Config.RestartPolicy.Delay", sc.String()))
+	}
+	if c.RestartPolicy.Window != 0 {
+		errs = append(errs, errUnsupported("Config.RestartPolicy.Window", sc.String()))
+	}
+	return errs
+}
+
 func (supervisedSystem) New(i Interface, c *Config) (Service, error) {
 	s := &supervisedService{
 		i:       i,
 		Config:  c,
-		procMgr: process.NewProcessManager(),
+		procMgr: supervisedProcesses,
 	}
 	s.procMgr.CreateProcess(s.parseConfig())
 	return s, nil
@@ -53,7 +79,15 @@ func (s *supervisedService) parseConfig() *process.ConfigEntry {
 	if stderr == "" {
 		redirect = "true"
 	}
-	return &process.ConfigEntry{
+	restart := s.restartPolicyOr(RestartPolicy{Mode: RestartAlways, Burst: 10})
+	autorestart := "true"
+	switch restart.Mode {
+	case RestartOnFailure:
+		autorestart = "unexpected"
+	case RestartNever:
+		autorestart = "false"
+	}
+	entry := &process.ConfigEntry{
 		Name: s.Name,
 		KeyValues: map[string]string{
 			"command":         s.Config.Executable,
@@ -63,18 +97,28 @@ func (s *supervisedService) parseConfig() *process.ConfigEntry {
 			"directory":       s.Config.WorkingDirectory,
 			"redirect_stderr": redirect,
 			"autostart":       "true",
-			"autorestart":     "true",
-			"startretries":    "10",
+			"autorestart":     autorestart,
+			"startretries":    strconv.Itoa(restart.Burst),
+			"exitcodes":       joinInts(append([]int{0}, restart.SuccessExitCodes...), ","),
 		},
 		Envs: s.Envs,
 	}
+	// Only the dependencies that are supervised services are started.
+	if deps := s.dependencyNames(dependRequires, dependWants, dependAfter); len(deps) > 0 {
+		entry.KeyValues["depends_on"] = strings.Join(deps, ",")
+	}
+	return entry
 }
 
 func (s *supervisedService) Install() error {
+	if err := s.Validate(system); err != nil {
+		return err
+	}
 	p := s.procMgr.Find(s.Name)
 	if p == nil {
 		return nil
 	}
+	s.procMgr.StartDependencies(s.Name)
 	p.Start(false)
 	return nil
 }
@@ -92,6 +136,9 @@ func (s *supervisedService) Uninstall() error {
 }
 
 func (s *supervisedService) Update() error {
+	if err := s.Validate(system); err != nil {
+		return err
+	}
 	p := s.procMgr.Find(s.Name)
 	if p == nil {
 		return nil
@@ -108,8 +155,9 @@ func (s *supervisedService) Run() error {
 	if p == nil {
 		return nil
 	}
+	s.procMgr.StartDependencies(s.Name)
 	p.Start(true)
-	return s.i.Stop(s)
+	return s.stopProgram(s.i, s)
 }
 
 func (s *supervisedService) Start() error {
@@ -121,6 +169,7 @@ func (s *supervisedService) Start() error {
 		return fmt.Errorf("empty executable")
 	}
 	if err := p.Attach(); err != nil {
+		s.procMgr.StartDependencies(s.Name)
 		p.Start(false)
 	}
 	return nil
@@ -144,6 +193,22 @@ func (s *supervisedService) Restart() error {
 	return nil
 }
 
+func (s *supervisedService) Reload() error {
+	p := s.procMgr.Find(s.Name)
+	if p == nil {
+		return fmt.Errorf("couldn't find program")
+	}
+	name := s.reloadSignalName(s.i)
+	if name == "" {
+		name = "HUP"
+	}
+	sig, err := signals.ToSignal(name)
+	if err != nil {
+		return err
+	}
+	return p.Signal(sig)
+}
+
 func (s *supervisedService) Logger(errs chan<- error) (Logger, error) {
 	return ConsoleLogger, nil
 }
@@ -171,10 +236,31 @@ func (s *supervisedService) PID() (int, error) {
 	return s.checkRunning()
 }
 
-func (s *supervisedService) Status() (string, error) {
-	pid, err := s.checkRunning()
-	if err != nil {
-		return "", err
+func (s *supervisedService) Status() (ServiceStatus, error) {
+	status := ServiceStatus{Backend: supervisedSystem{}.String()}
+	p := s.procMgr.Find(s.Name)
+	if p == nil {
+		status.State = StateNotInstalled
+		return status, nil
+	}
+	state := p.GetState()
+	if p.GetPid() == 0 && p.Attach() == nil {
+		state = p.GetState()
+	}
+	switch state {
+	case process.RUNNING:
+		status.State = StateRunning
+	case process.STARTING, process.BACKOFF:
+		status.State = StateActivating
+	case process.FATAL:
+		status.State = StateFailed
+	default:
+		status.State = StateStopped
+	}
+	if status.PID = p.GetPid(); status.PID > 0 {
+		status.StartTime = p.GetStartTime()
 	}
-	return "running (pid: " + strconv.Itoa(pid) + ")", nil
+	status.RestartCount = p.GetRetryTimes()
+	status.ExitCode = p.GetExitstatus()
+	return status, nil
 }
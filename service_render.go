@@ -0,0 +1,156 @@
+// Copyright 2015 Daniel Theophanes.
+// Use of this source code is governed by a zlib-style
+// license that can be found in the LICENSE file.
+
+package service
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Names of the service systems, as returned by System.String and reported
+// in ServiceStatus.Backend.
+const (
+	systemdName = "linux-systemd"
+	upstartName = "linux-upstart"
+	procdName   = "linux-procd"
+	openrcName  = "linux-openrc"
+	runitName   = "linux-runit"
+	sysvName    = "unix-systemv"
+	launchdName = "darwin-launchd"
+)
+
+// Renderer is implemented by services that can describe what Install would
+// do without changing the system, for example to review or test generated
+// files. Services on Linux and OS X implement Renderer.
+type Renderer interface {
+	// Render returns the files, symlinks and commands Install would create
+	// and run, in order. It does not require greater rights.
+	Render() (*Manifest, error)
+}
+
+// Manifest lists the side effects of installing a service.
+type Manifest struct {
+	Files    []ManifestFile
+	Symlinks []ManifestSymlink
+	Commands []Command
+}
+
+// ManifestFile is a file written by Install.
+type ManifestFile struct {
+	Path    string
+	Mode    os.FileMode
+	Content []byte
+}
+
+// ManifestSymlink is a symbolic link at Path pointing to Target.
+type ManifestSymlink struct {
+	Path   string
+	Target string
+}
+
+// Command is an external command run by Install after the files are written.
+type Command struct {
+	Name string
+	Args []string
+}
+
+func (c Command) String() string {
+	return strings.Join(append([]string{c.Name}, c.Args...), " ")
+}
+
+// ErrNoManifest is returned by InstallManifest for services that cannot
+// report what they install.
+var ErrNoManifest = errors.New("Service does not support install manifests.")
+
+type manifestInstaller interface {
+	install() (*Manifest, error)
+}
+
+// InstallManifest installs s like Install and returns the files and symlinks
+// created and the commands run. If the InstallRoot option is set, every path
+// is created under that root and includes it, and the commands are not run
+// but returned for the target system to run, for example from a package
+// post-install script. Services on Linux and OS X support InstallManifest.
+func InstallManifest(s Service) (*Manifest, error) {
+	mi, ok := s.(manifestInstaller)
+	if !ok {
+		return nil, ErrNoManifest
+	}
+	return mi.install()
+}
+
+func (c *Config) installRoot() string {
+	return c.Option.string(optionInstallRoot, "")
+}
+
+// joinInts joins numbers such as exit codes with sep.
+func joinInts(n []int, sep string) string {
+	s := make([]string, len(n))
+	for i, v := range n {
+		s[i] = strconv.Itoa(v)
+	}
+	return strings.Join(s, sep)
+}
+
+// RenderSystem returns what Install would create and run for c on the named
+// system, one of the names returned by System.String, regardless of the
+// running OS. Reload support is only rendered if the ReloadSignal option is
+// set, as there is no program to check for Reloader.
+func RenderSystem(name string, c *Config) (*Manifest, error) {
+	if c.scheduled() && (name == sysvName || name == upstartName) {
+		return (&cron{Config: c, system: name}).Render()
+	}
+	var r Renderer
+	switch name {
+	case systemdName:
+		r = &systemd{Config: c}
+	case sysvName:
+		r = &sysv{Config: c, offline: true}
+	case upstartName:
+		r = &upstart{Config: c, offline: true}
+	case procdName:
+		r = &procd{Config: c, offline: true}
+	case openrcName:
+		r = &openrc{Config: c}
+	case runitName:
+		r = &runit{Config: c}
+	case launchdName:
+		r = &darwinLaunchdService{
+			Config:      c,
+			userService: c.Option.bool(optionUserService, optionUserServiceDefault),
+		}
+	default:
+		return nil, fmt.Errorf("Cannot render a service for %q.", name)
+	}
+	return r.Render()
+}
+
+var tf = map[string]interface{}{
+	"cmd": func(s string) string {
+		return `"` + strings.Replace(s, `"`, `\"`, -1) + `"`
+	},
+	"cmdEscape": func(s string) string {
+		return strings.Replace(s, " ", `\x20`, -1)
+	},
+	// dquote quotes a value in double quotes, as read by systemd and Upstart.
+	"dquote": func(s string) string {
+		return `"` + dquoteReplacer.Replace(s) + `"`
+	},
+	"shquote": shquote,
+	// specifierEscape escapes the % specifiers of systemd unit files.
+	"specifierEscape": func(s string) string {
+		return strings.Replace(s, "%", "%%", -1)
+	},
+}
+
+var dquoteReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+
+// shquote quotes a value in single quotes for a POSIX shell.
+func shquote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
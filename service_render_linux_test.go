@@ -0,0 +1,650 @@
+// Copyright 2015 Daniel Theophanes.
+// Use of this source code is governed by a zlib-style
+// license that can be found in the LICENSE file.
+
+package service
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRenderLinux(t *testing.T) {
+	c := &Config{
+		Name:        "go_service_test",
+		Description: "Go service test.",
+		Executable:  "/usr/bin/go_service_test",
+		Arguments:   []string{"-flag", "value"},
+	}
+	tests := []struct {
+		new      func(i Interface, c *Config) (Service, error)
+		path     string
+		contains string
+		commands int
+	}{
+		{newSystemdService, "/etc/systemd/system/go_service_test.service", `ExecStart=/usr/bin/go_service_test "-flag" "value"`, 2},
+		{newOfflineSystemVService, "/etc/init.d/go_service_test", `# chkconfig: 2345 50 02`, 0},
+		{newUpstartService, "/etc/init/go_service_test.conf", `exec /usr/bin/go_service_test "-flag" "value"`, 0},
+		{newProcdService, "/etc/init.d/go_service_test", `procd_set_param command '/usr/bin/go_service_test' '-flag' 'value'`, 1},
+		{newOpenRCService, "/etc/init.d/go_service_test", `command_args="'-flag' 'value'"`, 1},
+	}
+	for _, tt := range tests {
+		s, err := tt.new(&contextProgram{}, c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		m, err := s.(Renderer).Render()
+		if err != nil {
+			t.Fatalf("%T Render err: %v", s, err)
+		}
+		if len(m.Files) != 1 || m.Files[0].Path != tt.path {
+			t.Fatalf("%T Render files: %+v, want %s", s, m.Files, tt.path)
+		}
+		if content := string(m.Files[0].Content); !strings.Contains(content, tt.contains) {
+			t.Errorf("%T Render content missing %q:\n%s", s, tt.contains, content)
+		}
+		if len(m.Commands) != tt.commands {
+			t.Errorf("%T Render commands: %v, want %d", s, m.Commands, tt.commands)
+		}
+	}
+}
+
+// newOfflineSystemVService creates a System V service that renders the
+// runlevel symlinks whatever the tools of the host.
+func newOfflineSystemVService(i Interface, c *Config) (Service, error) {
+	return &sysv{i: i, Config: c, offline: true}, nil
+}
+
+func TestInstallRoot(t *testing.T) {
+	root, err := ioutil.TempDir("", "go_service_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	c := &Config{
+		Name:       "go_service_test",
+		Executable: "/usr/bin/go_service_test",
+		Option:     KeyValue{optionInstallRoot: root},
+	}
+	for _, newService := range []func(i Interface, c *Config) (Service, error){newSystemdService, newSystemVService} {
+		s, err := newService(&contextProgram{}, c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rendered, err := s.(Renderer).Render()
+		if err != nil {
+			t.Fatal(err)
+		}
+		m, err := InstallManifest(s)
+		if err != nil {
+			t.Fatalf("%T InstallManifest err: %v", s, err)
+		}
+		for _, f := range m.Files {
+			if !strings.HasPrefix(f.Path, root) {
+				t.Errorf("%T file %s not under %s", s, f.Path, root)
+			}
+			if _, err := os.Stat(f.Path); err != nil {
+				t.Errorf("%T file not written: %v", s, err)
+			}
+		}
+		for _, l := range m.Symlinks {
+			if _, err := os.Lstat(l.Path); err != nil {
+				t.Errorf("%T symlink not created: %v", s, err)
+			}
+		}
+		if len(m.Commands) != len(rendered.Commands) {
+			t.Errorf("%T deferred commands: %v, want %v", s, m.Commands, rendered.Commands)
+		}
+	}
+}
+
+func TestRenderSystemdUser(t *testing.T) {
+	os.Setenv("XDG_CONFIG_HOME", "/home/gopher/.config")
+	defer os.Unsetenv("XDG_CONFIG_HOME")
+
+	c := &Config{
+		Name:       "go_service_test",
+		Executable: "/usr/bin/go_service_test",
+		Option:     KeyValue{optionUserService: true, optionLinger: true},
+	}
+	s, err := newSystemdService(&contextProgram{}, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := s.(Renderer).Render()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := m.Files[0].Path, "/home/gopher/.config/systemd/user/go_service_test.service"; got != want {
+		t.Errorf("user unit path %s, want %s", got, want)
+	}
+	if content := string(m.Files[0].Content); !strings.Contains(content, "WantedBy=default.target") {
+		t.Errorf("user unit not wanted by default.target:\n%s", content)
+	}
+	if m.Commands[0].Name != "loginctl" {
+		t.Errorf("first command %v, want loginctl enable-linger", m.Commands[0])
+	}
+	for _, cmd := range m.Commands[1:] {
+		if cmd.Args[0] != "--user" {
+			t.Errorf("command %v does not use the user service manager", cmd)
+		}
+	}
+}
+
+func TestRenderRestartPolicy(t *testing.T) {
+	c := &Config{
+		Name:       "go_service_test",
+		Executable: "/usr/bin/go_service_test",
+		RestartPolicy: RestartPolicy{
+			Mode:             RestartOnFailure,
+			Delay:            3 * time.Second,
+			Burst:            4,
+			Window:           time.Minute,
+			SuccessExitCodes: []int{3},
+		},
+	}
+	tests := []struct {
+		new      func(i Interface, c *Config) (Service, error)
+		contains []string
+	}{
+		{newSystemdService, []string{"Restart=on-failure", "RestartSec=3", "StartLimitBurst=4", "StartLimitInterval=60", "SuccessExitStatus=3"}},
+		{newUpstartService, []string{"respawn limit 4 60", "normal exit 0 3", "post-stop exec sleep 3"}},
+		{newProcdService, []string{"procd_set_param respawn 60 3 4"}},
+		{newOpenRCService, []string{"supervisor=supervise-daemon", "respawn_delay=3", "respawn_max=4", "respawn_period=60"}},
+	}
+	for _, tt := range tests {
+		s, err := tt.new(&contextProgram{}, c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		m, err := s.(Renderer).Render()
+		if err != nil {
+			t.Fatal(err)
+		}
+		content := string(m.Files[0].Content)
+		for _, want := range tt.contains {
+			if !strings.Contains(content, want) {
+				t.Errorf("%T Render content missing %q:\n%s", s, want, content)
+			}
+		}
+	}
+
+	c.RestartPolicy = RestartPolicy{Mode: RestartNever}
+	s, _ := newUpstartService(&contextProgram{}, c)
+	m, err := s.(Renderer).Render()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(m.Files[0].Content), "respawn") {
+		t.Errorf("upstart job respawns with RestartNever:\n%s", m.Files[0].Content)
+	}
+	s, _ = newOpenRCService(&contextProgram{}, c)
+	if m, err = s.(Renderer).Render(); err != nil {
+		t.Fatal(err)
+	}
+	if content := string(m.Files[0].Content); strings.Contains(content, "supervisor=") || !strings.Contains(content, "command_background=yes") {
+		t.Errorf("openrc-run script supervised with RestartNever:\n%s", content)
+	}
+}
+
+func TestRenderDependencies(t *testing.T) {
+	c := &Config{
+		Name:         "go_service_test",
+		Executable:   "/usr/bin/go_service_test",
+		Dependencies: []string{"postgresql", "Wants=$network", "After=syslog.target"},
+	}
+	tests := []struct {
+		new      func(i Interface, c *Config) (Service, error)
+		contains []string
+	}{
+		{newSystemdService, []string{"Requires=postgresql.service", "Wants=network-online.target", "After=postgresql.service network-online.target syslog.target"}},
+		{newSystemVService, []string{"# Provides:          go_service_test", "# Required-Start:    postgresql", "# Should-Start:      $network syslog"}},
+		{newUpstartService, []string{"and started postgresql"}},
+		{newOpenRCService, []string{"    need postgresql\n    want net\n"}},
+	}
+	for _, tt := range tests {
+		s, err := tt.new(&contextProgram{}, c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		m, err := s.(Renderer).Render()
+		if err != nil {
+			t.Fatal(err)
+		}
+		content := string(m.Files[0].Content)
+		for _, want := range tt.contains {
+			if !strings.Contains(content, want) {
+				t.Errorf("%T Render content missing %q:\n%s", s, want, content)
+			}
+		}
+	}
+
+	c.Dependencies = []string{"Before=postgresql"}
+	if err := c.Validate(nil); err == nil {
+		t.Error("Validate accepted an unknown dependency prefix")
+	}
+}
+
+func TestRenderSchedule(t *testing.T) {
+	c := &Config{
+		Name:       "go_service_test",
+		Executable: "/usr/bin/go_service_test",
+		Arguments:  []string{"-report", "100%"},
+		Schedule:   "Mon..Fri *-*-* 02:30",
+	}
+	m, err := RenderSystem(systemdName, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Files) != 2 || m.Files[1].Path != "/etc/systemd/system/go_service_test.timer" {
+		t.Fatalf("systemd files: %+v, want a service and a timer", m.Files)
+	}
+	if content := string(m.Files[0].Content); !strings.Contains(content, "Type=oneshot") || strings.Contains(content, "[Install]") {
+		t.Errorf("scheduled service is not a oneshot service without [Install]:\n%s", content)
+	}
+	if content := string(m.Files[1].Content); !strings.Contains(content, "OnCalendar=Mon..Fri *-*-* 02:30") {
+		t.Errorf("timer missing OnCalendar:\n%s", content)
+	}
+	if got := m.Commands[0].String(); got != "systemctl enable go_service_test.timer" {
+		t.Errorf("first command %q, want the timer enabled", got)
+	}
+
+	m, err = RenderSystem(sysvName, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Files) != 1 || m.Files[0].Path != "/etc/cron.d/go_service_test" {
+		t.Fatalf("sysv files: %+v, want a cron.d file", m.Files)
+	}
+	want := `30 2 * * 1,2,3,4,5 root set -a; [ -e /etc/sysconfig/go_service_test ] && . /etc/sysconfig/go_service_test; set +a; exec '/usr/bin/go_service_test' '-report' '100\%'`
+	if content := string(m.Files[0].Content); !strings.Contains(content, want) {
+		t.Errorf("cron.d file missing %q:\n%s", want, content)
+	}
+	if errs := validateProcdConfig(c); len(errs) != 1 {
+		t.Errorf("procd validation of a schedule: %v, want it rejected", errs)
+	}
+
+	c.Schedule = "10m"
+	m, err = RenderSystem(systemdName, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if content := string(m.Files[1].Content); !strings.Contains(content, "OnUnitActiveSec=600") {
+		t.Errorf("timer missing OnUnitActiveSec:\n%s", content)
+	}
+}
+
+func TestRenderProcdUser(t *testing.T) {
+	c := &Config{
+		Name:             "go_service_test",
+		Executable:       "/usr/bin/go_service_test",
+		Arguments:        []string{"-flag"},
+		UserName:         "nobody:nogroup",
+		WorkingDirectory: "/var/lib/go service",
+	}
+	m, err := RenderSystem(procdName, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content := string(m.Files[0].Content)
+	for _, want := range []string{
+		`procd_set_param command /bin/sh -c 'cd '\''/var/lib/go service'\'' && exec "$0" "$@"' '/usr/bin/go_service_test' '-flag'`,
+		"procd_set_param user nobody\n",
+		"procd_set_param group nogroup\n",
+	} {
+		if !strings.Contains(content, want) {
+			t.Errorf("procd script missing %q:\n%s", want, content)
+		}
+	}
+}
+
+func TestRenderRunit(t *testing.T) {
+	c := &Config{
+		Name:         "go_service_test",
+		Executable:   "/usr/bin/go_service_test",
+		Arguments:    []string{"-flag", "value"},
+		UserName:     "nobody",
+		Dependencies: []string{"postgresql", "After=syslog"},
+		RestartPolicy: RestartPolicy{
+			Mode:             RestartOnFailure,
+			Delay:            3 * time.Second,
+			SuccessExitCodes: []int{3},
+		},
+		Option: KeyValue{optionStandardOutPath: "/var/log/go_service_test"},
+	}
+	m, err := RenderSystem(runitName, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Symlinks) != 1 || m.Symlinks[0] != (ManifestSymlink{Path: "/var/service/go_service_test", Target: "/etc/sv/go_service_test"}) {
+		t.Errorf("symlinks: %+v, want the service directory linked into /var/service", m.Symlinks)
+	}
+	tests := []struct {
+		path     string
+		contains []string
+	}{
+		{"/etc/sv/go_service_test/run", []string{
+			"sv check '/var/service/postgresql' > /dev/null || exit 1\nexec 2>&1\n",
+			"exec chpst -u 'nobody' '/usr/bin/go_service_test' '-flag' 'value'\n",
+		}},
+		{"/etc/sv/go_service_test/finish", []string{"0|3) echo d > supervise/control ;;", "sleep 3"}},
+		{"/etc/sv/go_service_test/log/run", []string{"exec svlogd -tt '/var/log/go_service_test'"}},
+	}
+	if len(m.Files) != len(tests) {
+		t.Fatalf("files: %+v, want %d", m.Files, len(tests))
+	}
+	for i, tt := range tests {
+		f := m.Files[i]
+		if f.Path != tt.path || f.Mode != 0755 {
+			t.Errorf("file %d: %s %v, want %s executable", i, f.Path, f.Mode, tt.path)
+		}
+		for _, want := range tt.contains {
+			if !strings.Contains(string(f.Content), want) {
+				t.Errorf("%s missing %q:\n%s", f.Path, want, f.Content)
+			}
+		}
+	}
+
+	delete(c.Option, optionStandardOutPath)
+	if m, err = RenderSystem(runitName, c); err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Files) != 2 || strings.Contains(string(m.Files[0].Content), "exec 2>&1") {
+		t.Errorf("log service rendered without StandardOutPath: %+v", m.Files)
+	}
+}
+
+func TestRenderSystemVUser(t *testing.T) {
+	c := &Config{
+		Name:       "go_service_test",
+		Executable: "/usr/bin/go_service_test",
+		UserName:   "nobody:nogroup",
+		Option: KeyValue{
+			optionPIDFile:         "/run/go_service_test/pid",
+			optionStandardOutPath: "/var/log/go_service_test/out.log",
+		},
+	}
+	m, err := RenderSystem(sysvName, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content := string(m.Files[0].Content)
+	for _, want := range []string{
+		"user='nobody'\ngroup='nogroup'\npid_file='/run/go_service_test/pid'\n",
+		`runuser -u "$user" ${group:+-g "$group"}`,
+		`daemonize '/usr/bin/go_service_test' >> "$stdout_log" 2>&1 3> "$pid_file" &`,
+		"try-restart|condrestart)",
+	} {
+		if !strings.Contains(content, want) {
+			t.Errorf("init script missing %q:\n%s", want, content)
+		}
+	}
+
+	c.ChRoot = "/srv/jail"
+	c.WorkingDirectory = "/data"
+	m, err = RenderSystem(sysvName, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content = string(m.Files[0].Content)
+	want := `work_dir='/data' chroot ${user:+--userspec="$user${group:+:$group}"} '/srv/jail' /bin/sh -c 'cd "$work_dir" || exit 1; '"$script" "$@"`
+	if !strings.Contains(content, want) || strings.Contains(content, "cd '/data'") {
+		t.Errorf("init script does not run the program in the chroot:\n%s", content)
+	}
+	if errs := validateSystemVConfig(c); len(errs) != 0 {
+		t.Errorf("validateSystemVConfig: %v", errs)
+	}
+}
+
+// TestSystemVScript runs the actions of a rendered init script.
+func TestSystemVScript(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go_service_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	c := &Config{
+		Name:       "go_service_test",
+		Executable: "/bin/sleep",
+		Arguments:  []string{"30"},
+		Option: KeyValue{
+			optionPIDFile:           filepath.Join(dir, "pid"),
+			optionStandardOutPath:   filepath.Join(dir, "out.log"),
+			optionStandardErrorPath: filepath.Join(dir, "err.log"),
+		},
+	}
+	m, err := RenderSystem(sysvName, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	script := filepath.Join(dir, "go_service_test")
+	if err := ioutil.WriteFile(script, m.Files[0].Content, 0755); err != nil {
+		t.Fatal(err)
+	}
+	s := &sysv{Config: c}
+	action := func(name string, want int) {
+		err := exec.Command("/bin/sh", script, name).Run()
+		if code, _ := exitStatus(err); (err == nil && want != 0) || (err != nil && code != want) {
+			t.Errorf("%s: %v, want exit status %d", name, err, want)
+		}
+	}
+
+	action("status", 3)
+	action("reload", 3)
+	action("bogus", 2)
+	action("start", 0)
+	defer exec.Command("/bin/sh", script, "stop").Run()
+	action("status", 0)
+	pid, err := s.checkRunning()
+	if err != nil || pid <= 0 {
+		t.Errorf("checkRunning of a started service: %d, %v", pid, err)
+	}
+	action("try-restart", 0)
+	if p, err := s.checkRunning(); err != nil || p == pid {
+		t.Errorf("checkRunning after try-restart: %d, %v, want a new process", p, err)
+	}
+	action("stop", 0)
+	action("status", 3)
+	if _, err := s.checkRunning(); err != ErrServiceIsNotRunning {
+		t.Errorf("checkRunning of a stopped service: %v, want %v", err, ErrServiceIsNotRunning)
+	}
+
+	if err := ioutil.WriteFile(c.Option[optionPIDFile].(string), []byte("999999\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	action("status", 1)
+}
+
+func TestInstallOrUpdate(t *testing.T) {
+	root, err := ioutil.TempDir("", "go_service_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	c := &Config{
+		Name:       "go_service_test",
+		Executable: "/usr/bin/go_service_test",
+		Option:     KeyValue{optionInstallRoot: root},
+	}
+	s, err := newSystemVService(&contextProgram{}, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := InstallOrUpdate(s); err != nil {
+		t.Fatalf("InstallOrUpdate of a new service: %v", err)
+	}
+	path := filepath.Join(root, "/etc/init.d/go_service_test")
+	old := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(path, old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := s.(Renderer).Render()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changed, err := c.update(m); err != nil || changed {
+		t.Errorf("update of an unchanged service: %v, %v, want no change", changed, err)
+	}
+	if fi, err := os.Stat(path); err != nil || !fi.ModTime().Equal(old) {
+		t.Errorf("unchanged init script rewritten: %v", err)
+	}
+
+	c.Description = "Changed description."
+	if err := s.Update(); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if content, err := ioutil.ReadFile(path); err != nil || !strings.Contains(string(content), c.Description) {
+		t.Errorf("init script not updated: %v\n%s", err, content)
+	}
+
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Update(); err != ErrServiceIsNotInstalled {
+		t.Errorf("Update of a missing service: %v, want %v", err, ErrServiceIsNotInstalled)
+	}
+}
+
+// failingCommander records the commands and fails the one named fail.
+type failingCommander struct {
+	lines []string
+	fail  string
+}
+
+func (c *failingCommander) Run(name string, args ...string) error {
+	_, err := c.Output(name, args...)
+	return err
+}
+
+func (c *failingCommander) Output(name string, args ...string) ([]byte, error) {
+	line := Command{Name: name, Args: args}.String()
+	c.lines = append(c.lines, line)
+	if line == c.fail {
+		return nil, errors.New("exit status 1")
+	}
+	return nil, nil
+}
+
+func TestApplyRollback(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go_service_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	existing := filepath.Join(dir, "existing")
+	if err := ioutil.WriteFile(existing, []byte("old"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	created := filepath.Join(dir, "created")
+	link := filepath.Join(dir, "rc2.d", "S50created")
+
+	cmd := &failingCommander{fail: "systemctl start go_service_test.service"}
+	c := &Config{Name: "go_service_test", Commander: cmd}
+	_, err = c.apply(&Manifest{
+		Files: []ManifestFile{
+			{Path: existing, Mode: 0644, Content: []byte("new")},
+			{Path: created, Mode: 0644, Content: []byte("new")},
+		},
+		Symlinks: []ManifestSymlink{{Path: link, Target: created}},
+		Commands: []Command{
+			{Name: "systemctl", Args: []string{"enable", "go_service_test.service"}},
+			{Name: "systemctl", Args: []string{"daemon-reload"}},
+			{Name: "systemctl", Args: []string{"start", "go_service_test.service"}},
+		},
+	})
+	if err == nil {
+		t.Fatal("apply succeeded with a failing command")
+	}
+
+	if content, err := ioutil.ReadFile(existing); err != nil || string(content) != "old" {
+		t.Errorf("existing file not restored: %q, %v", content, err)
+	}
+	if fi, err := os.Stat(existing); err != nil || fi.Mode().Perm() != 0600 {
+		t.Errorf("existing file mode not restored: %v", err)
+	}
+	if _, err := os.Stat(created); !os.IsNotExist(err) {
+		t.Errorf("created file not removed: %v", err)
+	}
+	if _, err := os.Lstat(link); !os.IsNotExist(err) {
+		t.Errorf("symlink not removed: %v", err)
+	}
+	want := []string{
+		"systemctl enable go_service_test.service",
+		"systemctl daemon-reload",
+		"systemctl start go_service_test.service",
+		"systemctl disable go_service_test.service",
+		"systemctl daemon-reload",
+	}
+	if strings.Join(cmd.lines, "\n") != strings.Join(want, "\n") {
+		t.Errorf("commands run: %q, want %q", cmd.lines, want)
+	}
+}
+
+func TestUpdateRollback(t *testing.T) {
+	dir, err := ioutil.TempDir("", "go_service_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	unit := filepath.Join(dir, "go_service_test.service")
+	if err := ioutil.WriteFile(unit, []byte("old"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cmd := &failingCommander{fail: "systemctl daemon-reload"}
+	c := &Config{Name: "go_service_test", Commander: cmd}
+	_, err = c.update(&Manifest{
+		Files: []ManifestFile{{Path: unit, Mode: 0644, Content: []byte("new")}},
+		Commands: []Command{
+			{Name: "systemctl", Args: []string{"enable", "go_service_test.service"}},
+			{Name: "systemctl", Args: []string{"daemon-reload"}},
+		},
+	})
+	if err == nil {
+		t.Fatal("update succeeded with a failing command")
+	}
+	if content, err := ioutil.ReadFile(unit); err != nil || string(content) != "old" {
+		t.Errorf("unit not restored: %q, %v", content, err)
+	}
+	// The service was enabled before the update, it must stay enabled.
+	want := []string{
+		"systemctl enable go_service_test.service",
+		"systemctl daemon-reload",
+	}
+	if strings.Join(cmd.lines, "\n") != strings.Join(want, "\n") {
+		t.Errorf("commands run: %q, want %q", cmd.lines, want)
+	}
+}
+
+func TestUndoCommand(t *testing.T) {
+	tests := []struct {
+		cmd, undo Command
+	}{
+		{Command{"systemctl", []string{"--user", "enable", "app.service"}}, Command{"systemctl", []string{"--user", "disable", "app.service"}}},
+		{Command{"/etc/init.d/app", []string{"enable"}}, Command{"/etc/init.d/app", []string{"disable"}}},
+		{Command{"/usr/sbin/update-rc.d", []string{"app", "defaults", "50", "02"}}, Command{"/usr/sbin/update-rc.d", []string{"-f", "app", "remove"}}},
+		{Command{"chkconfig", []string{"--add", "app"}}, Command{"chkconfig", []string{"--del", "app"}}},
+		{Command{"insserv", []string{"app"}}, Command{"insserv", []string{"-r", "app"}}},
+		{Command{"rc-update", []string{"add", "app", "default"}}, Command{"rc-update", []string{"del", "app", "default"}}},
+	}
+	for _, tt := range tests {
+		undo, ok := undoCommand(tt.cmd)
+		if !ok || undo.String() != tt.undo.String() {
+			t.Errorf("undo of %q: %q, want %q", tt.cmd, undo, tt.undo)
+		}
+	}
+	if undo, ok := undoCommand(Command{"update-rc.d", []string{"-f", "app", "remove"}}); ok {
+		t.Errorf("remove undone with %q", undo)
+	}
+}
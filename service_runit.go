@@ -0,0 +1,153 @@
+// Copyright 2015 Daniel Theophanes.
+// Use of this source code is governed by a zlib-style
+// license that can be found in the LICENSE file.
+
+package service
+
+import (
+	"bytes"
+	"path"
+	"strings"
+	"text/template"
+)
+
+// runit runs the service from a service directory in /etc/sv, linked into
+// the directory watched by runsvdir, as on Void Linux and in containers.
+type runit struct {
+	i Interface
+	*Config
+
+	// serviceDir is the directory watched by runsvdir, /var/service if
+	// empty.
+	serviceDir string
+}
+
+const runitServiceDir = "/var/service"
+
+// svDir returns the service directory holding the run script.
+func (s *runit) svDir() string {
+	return "/etc/sv/" + s.Name
+}
+
+// linkPath returns the link to the service directory read by runsvdir, sv
+// controls the service through it.
+func (s *runit) linkPath() string {
+	dir := s.serviceDir
+	if len(dir) == 0 {
+		dir = runitServiceDir
+	}
+	return path.Join(dir, s.Name)
+}
+
+func (s *runit) configPath() string {
+	return s.svDir() + "/run"
+}
+
+func (s *runit) template(text string) *template.Template {
+	return template.Must(template.New("").Funcs(tf).Parse(text))
+}
+
+// Render returns the run and finish scripts of the service directory, the
+// log/run script of svlogd if the StandardOutPath option is set, and the
+// link runsvdir starts the service from.
+func (s *runit) Render() (*Manifest, error) {
+	execPath, err := s.execPath()
+	if err != nil {
+		return nil, err
+	}
+	args := s.Arguments
+	if cmd := strings.Split(execPath, " "); len(cmd) > 1 {
+		execPath = cmd[0]
+		args = append(cmd[1:], args...)
+	}
+	var requires []string
+	for _, name := range s.dependencyNames(dependRequires) {
+		if job := jobName(name); len(job) > 0 {
+			requires = append(requires, path.Join(path.Dir(s.linkPath()), job))
+		}
+	}
+
+	// runsv restarts the program whatever the exit status unless finish
+	// takes the service down.
+	restart := s.restartPolicyOr(RestartPolicy{Mode: RestartAlways})
+
+	var to = &struct {
+		*Config
+		Path      string
+		Args      []string
+		Requires  []string
+		LogDir    string
+		StderrLog string
+
+		Restart      RestartMode
+		RestartDelay int
+		SuccessCodes string
+	}{
+		s.Config,
+		execPath,
+		args,
+		requires,
+		s.Option.string(optionStandardOutPath, ""),
+		s.Option.string(optionStandardErrorPath, ""),
+
+		restart.Mode,
+		seconds(restart.Delay),
+		joinInts(append([]int{0}, restart.SuccessExitCodes...), "|"),
+	}
+
+	m := &Manifest{
+		Symlinks: []ManifestSymlink{{Path: s.linkPath(), Target: s.svDir()}},
+	}
+	scripts := []struct{ path, text string }{
+		{s.configPath(), runitRunScript},
+		{s.svDir() + "/finish", runitFinishScript},
+	}
+	if len(to.LogDir) > 0 {
+		scripts = append(scripts, struct{ path, text string }{s.svDir() + "/log/run", runitLogScript})
+	}
+	for _, script := range scripts {
+		var buf bytes.Buffer
+		if err := s.template(script.text).Execute(&buf, to); err != nil {
+			return nil, err
+		}
+		m.Files = append(m.Files, ManifestFile{Path: script.path, Mode: 0755, Content: buf.Bytes()})
+	}
+	return m, nil
+}
+
+const runitRunScript = `#!/bin/sh
+# {{.Description}}
+{{range .Requires}}
+sv check {{.|shquote}} > /dev/null || exit 1
+{{- end}}
+{{if .StderrLog}}exec 2>> {{.StderrLog|shquote}}
+{{else if .LogDir}}exec 2>&1
+{{end}}
+{{- range $k, $v := .Envs}}export {{$k}}={{$v | shquote}}
+{{end}}
+{{- if .WorkingDirectory}}cd {{.WorkingDirectory|shquote}} || exit 1
+{{end}}exec chpst{{if .UserName}} -u {{.UserName|shquote}}{{end}}{{if .ChRoot}} -/ {{.ChRoot|shquote}}{{end}} {{.Path|shquote}}{{range .Args}} {{.|shquote}}{{end}}
+`
+
+// runitFinishScript runs once the program exited, with its exit status or
+// -1 if it was killed. Writing d to the control pipe of runsv keeps it down.
+const runitFinishScript = `#!/bin/sh
+{{- if eq .Restart "never"}}
+echo d > supervise/control
+{{- else if eq .Restart "on-failure"}}
+case "$1" in
+{{.SuccessCodes}}) echo d > supervise/control ;;
+esac
+{{- end}}
+{{- if .RestartDelay}}
+sleep {{.RestartDelay}}
+{{- end}}
+exit 0
+`
+
+// runitLogScript reads the output of the run script, svlogd writes it to the
+// file current in the log directory.
+const runitLogScript = `#!/bin/sh
+mkdir -p {{.LogDir|shquote}}
+exec svlogd -tt {{.LogDir|shquote}}
+`
@@ -0,0 +1,245 @@
+// Copyright 2015 Daniel Theophanes.
+// Use of this source code is governed by a zlib-style
+// license that can be found in the LICENSE file.
+
+package service
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// isRunit reports if runsvdir is PID 1, as in containers, or its directory
+// exists, as where runit is the init system.
+func isRunit() bool {
+	if len(runsvdirDir()) > 0 {
+		return true
+	}
+	if fi, err := os.Stat(runitServiceDir); err == nil && fi.IsDir() {
+		return true
+	}
+	return false
+}
+
+// runsvdirDir returns the directory watched by runsvdir if it is PID 1.
+func runsvdirDir() string {
+	cmdline, err := ioutil.ReadFile("/proc/1/cmdline")
+	if err != nil {
+		return ""
+	}
+	args := strings.Split(string(bytes.TrimRight(cmdline, "\x00")), "\x00")
+	if filepath.Base(args[0]) != "runsvdir" {
+		return ""
+	}
+	for _, arg := range args[1:] {
+		if !strings.HasPrefix(arg, "-") {
+			return arg
+		}
+	}
+	return ""
+}
+
+func newRunitService(i Interface, c *Config) (Service, error) {
+	s := &runit{
+		i:          i,
+		Config:     c,
+		serviceDir: runsvdirDir(),
+	}
+
+	return s, nil
+}
+
+func (s *runit) String() string {
+	if len(s.DisplayName) > 0 {
+		return s.DisplayName
+	}
+	return s.Name
+}
+
+func validateRunitConfig(c *Config) []error {
+	var errs []error
+	if c.Option.bool(optionUserService, optionUserServiceDefault) {
+		errs = append(errs, errUnsupported("UserService", runitName))
+	}
+	if c.scheduled() {
+		errs = append(errs, errUnsupported("Config.Schedule", runitName))
+	}
+	return errs
+}
+
+func (s *runit) Install() error {
+	_, err := s.install()
+	return err
+}
+
+func (s *runit) install() (*Manifest, error) {
+	if err := s.Validate(system); err != nil {
+		return nil, err
+	}
+	confPath := s.configPath()
+	_, err := os.Stat(filepath.Join(s.installRoot(), confPath))
+	if err == nil {
+		return nil, fmt.Errorf("Init already exists: %s", confPath)
+	}
+
+	m, err := s.Render()
+	if err != nil {
+		return nil, err
+	}
+	return s.apply(m)
+}
+
+// Uninstall stops the service, removes the link so runsvdir stops its runsv
+// and then removes the service directory.
+func (s *runit) Uninstall() error {
+	s.Stop()
+	if err := os.Remove(s.linkPath()); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return os.RemoveAll(s.svDir())
+}
+
+func (s *runit) Logger(errs chan<- error) (Logger, error) {
+	if system.Interactive() {
+		return ConsoleLogger, nil
+	}
+	return s.SystemLogger(errs)
+}
+
+func (s *runit) SystemLogger(errs chan<- error) (Logger, error) {
+	return newSysLogger(s.Name, errs)
+}
+
+func (s *runit) Run() (err error) {
+	err = s.startProgram(s.i, s)
+	if err != nil {
+		return err
+	}
+
+	s.Option.funcSingle(optionRunWait, func() {
+		s.waitForSignal(s.i, s, syscall.SIGTERM, os.Interrupt)
+	})()
+
+	return s.stopProgram(s.i, s)
+}
+
+// sv runs sv with the command for the service. sv waits the StopTimeout for
+// the program to stop, or its own default if the option is not set.
+func (s *runit) sv(command string) error {
+	if secs := s.timeoutSeconds(optionStopTimeout, optionStopTimeoutDefault); secs > 0 {
+		return s.run("sv", "-w", strconv.Itoa(secs), command, s.linkPath())
+	}
+	return s.run("sv", command, s.linkPath())
+}
+
+func (s *runit) Start() error {
+	return s.run("sv", "start", s.linkPath())
+}
+
+func (s *runit) Stop() error {
+	return s.sv("stop")
+}
+
+func (s *runit) Restart() error {
+	return s.sv("restart")
+}
+
+// runitSignals are the signals sv sends to the program, by name.
+var runitSignals = map[string]string{
+	"HUP":  "hup",
+	"INT":  "interrupt",
+	"QUIT": "quit",
+	"ALRM": "alarm",
+	"USR1": "1",
+	"USR2": "2",
+	"TERM": "term",
+}
+
+// Reload sends the ReloadSignal with sv, which only sends some signals.
+func (s *runit) Reload() error {
+	name := s.reloadSignalName(s.i)
+	if len(name) == 0 {
+		return errNoReloadSignal
+	}
+	command, found := runitSignals[name]
+	if !found {
+		return fmt.Errorf("sv cannot send the ReloadSignal %s.", name)
+	}
+	return s.run("sv", command, s.linkPath())
+}
+
+// Update rewrites the installed files that differ from what Install would
+// write. runsv runs the new scripts when the program restarts.
+func (s *runit) Update() error {
+	return updateService(s)
+}
+
+var runitStatusRe = regexp.MustCompile(`^([a-z]+): [^:]+: (?:\(pid ([0-9]+)\) )?([0-9]+)s`)
+
+// status runs sv status. runsv reports the state and for how many seconds the
+// service has been in it.
+func (s *runit) status() (state string, pid int, since time.Time, err error) {
+	output, err := s.runWithOutput("sv", "status", s.linkPath())
+	if err != nil {
+		if _, ok := exitStatus(err); !ok {
+			return "", -1, since, err
+		}
+	}
+	data := runitStatusRe.FindStringSubmatch(string(output))
+	if data == nil {
+		return "", -1, since, nil
+	}
+	pid = -1
+	if len(data[2]) > 0 {
+		pid, _ = strconv.Atoi(data[2])
+	}
+	secs, _ := strconv.Atoi(data[3])
+	return data[1], pid, time.Now().Add(-time.Duration(secs) * time.Second), nil
+}
+
+func (s *runit) PID() (int, error) {
+	state, pid, _, err := s.status()
+	if err != nil {
+		return -1, err
+	}
+	if state != "run" || pid <= 0 {
+		return -1, ErrServiceIsNotRunning
+	}
+	return pid, nil
+}
+
+// Status - Get service status
+func (s *runit) Status() (ServiceStatus, error) {
+	status := ServiceStatus{Backend: runitName}
+	if _, err := os.Stat(s.configPath()); os.IsNotExist(err) {
+		status.State = StateNotInstalled
+		return status, nil
+	}
+
+	state, pid, since, err := s.status()
+	if err != nil {
+		return status, err
+	}
+	switch state {
+	case "run":
+		status.State = StateRunning
+		status.PID = pid
+		status.StartTime = since
+	case "down":
+		status.State = StateStopped
+	case "finish":
+		status.State = StateActivating
+	default:
+		// sv cannot tell the state before runsvdir started runsv.
+		status.State = StateUnknown
+	}
+	return status, nil
+}
@@ -0,0 +1,318 @@
+// Copyright 2015 Daniel Theophanes.
+// Use of this source code is governed by a zlib-style
+// license that can be found in the LICENSE file.
+
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// scheduled reports if the service runs on a Schedule rather than always.
+func (c *Config) scheduled() bool {
+	return len(c.Schedule) > 0
+}
+
+// scheduleInterval returns the Schedule as an interval, or false if it is a
+// calendar expression.
+func (c *Config) scheduleInterval() (time.Duration, bool) {
+	d, err := time.ParseDuration(c.Schedule)
+	return d, err == nil
+}
+
+// runWait returns the function Run waits on between starting and stopping
+// the program: the RunWait option, or wait. A scheduled run ends when Start
+// returns, so there is nothing to wait for.
+func (c *Config) runWait(wait func()) func() {
+	if c.scheduled() {
+		return func() {}
+	}
+	return c.Option.funcSingle(optionRunWait, wait)
+}
+
+// cronSchedule holds the bits of the values of each field of a crontab
+// entry. At most one of dom and dow is restricted, cron would run the job
+// on the days matching either.
+type cronSchedule struct {
+	minute, hour, dom, month, dow uint64
+}
+
+// cronSchedule converts the Schedule to cron fields.
+func (c *Config) cronSchedule() (cronSchedule, error) {
+	var cs cronSchedule
+	var err error
+	if d, ok := c.scheduleInterval(); ok {
+		cs, err = intervalCron(d)
+	} else {
+		cs, err = calendarCron(c.Schedule)
+	}
+	if err != nil {
+		return cs, fmt.Errorf("Config.Schedule %q cannot be run by cron: %v.", c.Schedule, err)
+	}
+	return cs, nil
+}
+
+// validateCronSchedule is used by the systems that run scheduled services
+// with cron.
+func validateCronSchedule(c *Config) []error {
+	if !c.scheduled() {
+		return nil
+	}
+	if _, err := c.cronSchedule(); err != nil {
+		return []error{err}
+	}
+	return nil
+}
+
+// bits returns the bits of the values from min to max, every step.
+func bits(min, max, step int) uint64 {
+	var b uint64
+	for v := min; v <= max; v += step {
+		b |= 1 << uint(v)
+	}
+	return b
+}
+
+// intervalCron returns the cron fields of an interval that divides an hour
+// or a day evenly.
+func intervalCron(d time.Duration) (cronSchedule, error) {
+	cs := cronSchedule{hour: bits(0, 23, 1), dom: bits(1, 31, 1), month: bits(1, 12, 1), dow: bits(0, 6, 1)}
+	m := int(d / time.Minute)
+	switch {
+	case d%time.Minute != 0 || m == 0:
+		return cs, errors.New("the interval is not a whole number of minutes")
+	case m < 60 && 60%m == 0:
+		cs.minute = bits(0, 59, m)
+	case m%60 == 0 && 24%(m/60) == 0:
+		cs.minute = 1
+		cs.hour = bits(0, 23, m/60)
+	default:
+		return cs, errors.New("the interval does not divide an hour or a day")
+	}
+	return cs, nil
+}
+
+// calendarShorthands are the systemd calendar expressions with a name.
+var calendarShorthands = map[string]string{
+	"minutely": "*-*-* *:*:00",
+	"hourly":   "*-*-* *:00:00",
+	"daily":    "*-*-* 00:00:00",
+	"weekly":   "Mon *-*-* 00:00:00",
+	"monthly":  "*-*-01 00:00:00",
+	"yearly":   "*-01-01 00:00:00",
+	"annually": "*-01-01 00:00:00",
+}
+
+var weekdays = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+// calendarCron returns the cron fields of a systemd calendar expression of
+// the form "[weekdays] [[*-]month-day] [hour:minute[:00]]", such as
+// "Mon..Fri *-*-* 02:30" or "*-*-01,15 06:00". Each value is *, a number, a
+// range such as 1..5, a list of those separated by commas, or a start
+// followed by /step. The shorthands such as daily are accepted as well.
+func calendarCron(expr string) (cronSchedule, error) {
+	cs := cronSchedule{dom: bits(1, 31, 1), month: bits(1, 12, 1), dow: bits(0, 6, 1)}
+	if long, found := calendarShorthands[strings.ToLower(expr)]; found {
+		expr = long
+	}
+	fields := strings.Fields(expr)
+	if len(fields) > 0 && unicode.IsLetter(rune(fields[0][0])) {
+		var err error
+		if cs.dow, err = parseWeekdays(fields[0]); err != nil {
+			return cs, err
+		}
+		fields = fields[1:]
+	}
+	if len(fields) > 0 && strings.Contains(fields[0], "-") {
+		date := strings.Split(fields[0], "-")
+		if len(date) == 3 {
+			if date[0] != "*" {
+				return cs, errors.New("cron cannot restrict the year")
+			}
+			date = date[1:]
+		}
+		if len(date) != 2 {
+			return cs, fmt.Errorf("invalid date %q", fields[0])
+		}
+		var err error
+		if cs.month, err = parseCalendarField(date[0], 1, 12); err != nil {
+			return cs, err
+		}
+		if cs.dom, err = parseCalendarField(date[1], 1, 31); err != nil {
+			return cs, err
+		}
+		fields = fields[1:]
+	}
+	clock := "00:00:00"
+	if len(fields) > 0 && strings.Contains(fields[0], ":") {
+		clock = fields[0]
+		fields = fields[1:]
+	}
+	if len(fields) > 0 {
+		return cs, fmt.Errorf("unsupported %q", strings.Join(fields, " "))
+	}
+	parts := strings.Split(clock, ":")
+	if len(parts) == 3 {
+		if s, err := parseCalendarField(parts[2], 0, 59); err != nil || s != 1 {
+			return cs, errors.New("cron only runs jobs at whole minutes")
+		}
+		parts = parts[:2]
+	}
+	if len(parts) != 2 {
+		return cs, fmt.Errorf("invalid time %q", clock)
+	}
+	var err error
+	if cs.hour, err = parseCalendarField(parts[0], 0, 23); err != nil {
+		return cs, err
+	}
+	if cs.minute, err = parseCalendarField(parts[1], 0, 59); err != nil {
+		return cs, err
+	}
+	if cs.dom != bits(1, 31, 1) && cs.dow != bits(0, 6, 1) {
+		return cs, errors.New("cron cannot restrict both the day of the month and the weekday")
+	}
+	return cs, nil
+}
+
+// parseCalendarField returns the bits of the values of a calendar field.
+func parseCalendarField(field string, min, max int) (uint64, error) {
+	var b uint64
+	for _, item := range strings.Split(field, ",") {
+		step := 1
+		if pos := strings.Index(item, "/"); pos != -1 {
+			var err error
+			if step, err = strconv.Atoi(item[pos+1:]); err != nil || step < 1 {
+				return 0, fmt.Errorf("invalid step in %q", field)
+			}
+			item = item[:pos]
+		}
+		from, to := min, max
+		if item != "*" {
+			bounds := strings.SplitN(item, "..", 2)
+			var err error
+			if from, err = strconv.Atoi(bounds[0]); err != nil {
+				return 0, fmt.Errorf("invalid value %q", field)
+			}
+			to = from
+			if len(bounds) == 2 {
+				if to, err = strconv.Atoi(bounds[1]); err != nil {
+					return 0, fmt.Errorf("invalid value %q", field)
+				}
+			} else if step > 1 {
+				to = max
+			}
+		}
+		if from < min || to > max || from > to {
+			return 0, fmt.Errorf("%q is out of the range %d..%d", field, min, max)
+		}
+		b |= bits(from, to, step)
+	}
+	return b, nil
+}
+
+// parseWeekdays returns the bits of weekdays such as "Mon..Fri,Sun", with
+// Sunday as 0 like cron.
+func parseWeekdays(field string) (uint64, error) {
+	var b uint64
+	for _, item := range strings.Split(field, ",") {
+		bounds := strings.SplitN(item, "..", 2)
+		var days []int
+		for _, name := range bounds {
+			day := -1
+			for i, wd := range weekdays {
+				if len(name) >= 3 && strings.HasPrefix(strings.ToLower(name), wd) {
+					day = i
+				}
+			}
+			if day == -1 {
+				return 0, fmt.Errorf("invalid weekday %q", name)
+			}
+			days = append(days, day)
+		}
+		if len(days) == 1 {
+			b |= 1 << uint(days[0])
+			continue
+		}
+		// Ranges may wrap around the week, as in Sat..Mon.
+		for d := days[0]; ; d = (d + 1) % 7 {
+			b |= 1 << uint(d)
+			if d == days[1] {
+				break
+			}
+		}
+	}
+	return b, nil
+}
+
+// String returns the five time fields of a crontab entry.
+func (cs cronSchedule) String() string {
+	return strings.Join([]string{
+		cronField(cs.minute, 0, 59),
+		cronField(cs.hour, 0, 23),
+		cronField(cs.dom, 1, 31),
+		cronField(cs.month, 1, 12),
+		cronField(cs.dow, 0, 6),
+	}, " ")
+}
+
+func cronField(b uint64, min, max int) string {
+	if b == bits(min, max, 1) {
+		return "*"
+	}
+	var values []string
+	for v := min; v <= max; v++ {
+		if b&(1<<uint(v)) != 0 {
+			values = append(values, strconv.Itoa(v))
+		}
+	}
+	return strings.Join(values, ",")
+}
+
+func (cs cronSchedule) matchDay(t time.Time) bool {
+	return cs.month&(1<<uint(t.Month())) != 0 &&
+		cs.dom&(1<<uint(t.Day())) != 0 &&
+		cs.dow&(1<<uint(t.Weekday())) != 0
+}
+
+// next returns the first time after t cron starts the job, or the zero
+// time if it never does, as for February 30.
+func (cs cronSchedule) next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	for limit := t.AddDate(5, 0, 0); t.Before(limit); {
+		switch {
+		case !cs.matchDay(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+		case cs.hour&(1<<uint(t.Hour())) == 0:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+		case cs.minute&(1<<uint(t.Minute())) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// prev returns the last time at or before t cron started the job, or the
+// zero time if it never did.
+func (cs cronSchedule) prev(t time.Time) time.Time {
+	t = t.Truncate(time.Minute)
+	for limit := t.AddDate(-5, 0, 0); t.After(limit); {
+		switch {
+		case !cs.matchDay(t):
+			t = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location()).Add(-time.Minute)
+		case cs.hour&(1<<uint(t.Hour())) == 0:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, t.Location()).Add(-time.Minute)
+		case cs.minute&(1<<uint(t.Minute())) == 0:
+			t = t.Add(-time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
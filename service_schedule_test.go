@@ -0,0 +1,71 @@
+// Copyright 2015 Daniel Theophanes.
+// Use of this source code is governed by a zlib-style
+// license that can be found in the LICENSE file.
+
+package service
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCronSchedule(t *testing.T) {
+	tests := []struct {
+		schedule string
+		cron     string
+	}{
+		{"15m", "0,15,30,45 * * * *"},
+		{"1m", "* * * * *"},
+		{"6h", "0 0,6,12,18 * * *"},
+		{"24h", "0 0 * * *"},
+		{"daily", "0 0 * * *"},
+		{"weekly", "0 0 * * 1"},
+		{"hourly", "0 * * * *"},
+		{"Mon..Fri *-*-* 02:30", "30 2 * * 1,2,3,4,5"},
+		{"Sat..Mon 12:00:00", "0 12 * * 0,1,6"},
+		{"*-*-01,15 06:00", "0 6 1,15 * *"},
+		{"*-01..03-01 *:0/20", "0,20,40 * 1 1,2,3 *"},
+	}
+	for _, tt := range tests {
+		c := &Config{Schedule: tt.schedule}
+		cs, err := c.cronSchedule()
+		if err != nil {
+			t.Errorf("%q: %v", tt.schedule, err)
+			continue
+		}
+		if got := cs.String(); got != tt.cron {
+			t.Errorf("%q: got %q, want %q", tt.schedule, got, tt.cron)
+		}
+	}
+
+	for _, schedule := range []string{"7m", "90s", "2017-*-* 00:00", "*-*-* 00:00:30", "Mon *-*-01", "Funday", "daily UTC"} {
+		c := &Config{Schedule: schedule}
+		if _, err := c.cronSchedule(); err == nil {
+			t.Errorf("%q: converted to cron", schedule)
+		}
+	}
+}
+
+func TestCronScheduleRuns(t *testing.T) {
+	c := &Config{Schedule: "Mon..Fri 02:30"}
+	cs, err := c.cronSchedule()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Friday afternoon.
+	now := time.Date(2017, 5, 5, 15, 4, 5, 0, time.UTC)
+	if got, want := cs.next(now), time.Date(2017, 5, 8, 2, 30, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("next run %v, want %v", got, want)
+	}
+	if got, want := cs.prev(now), time.Date(2017, 5, 5, 2, 30, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("last run %v, want %v", got, want)
+	}
+
+	c.Schedule = "*-02-30 00:00"
+	if cs, err = c.cronSchedule(); err != nil {
+		t.Fatal(err)
+	}
+	if next := cs.next(now); !next.IsZero() {
+		t.Errorf("next run of February 30 %v", next)
+	}
+}
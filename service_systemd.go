@@ -0,0 +1,311 @@
+// Copyright 2015 Daniel Theophanes.
+// Use of this source code is governed by a zlib-style
+// license that can be found in the LICENSE file.
+
+package service
+
+import (
+	"bytes"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+)
+
+type systemd struct {
+	i Interface
+	*Config
+}
+
+func (s *systemd) userService() bool {
+	return s.Option.bool(optionUserService, optionUserServiceDefault)
+}
+
+func (s *systemd) configPath() (cp string, err error) {
+	if s.userService() {
+		dir := os.Getenv("XDG_CONFIG_HOME")
+		if len(dir) == 0 {
+			homeDir, err := getHomeDir()
+			if err != nil {
+				return "", err
+			}
+			dir = filepath.Join(homeDir, ".config")
+		}
+		cp = filepath.Join(dir, "systemd", "user", s.Config.Name+".service")
+		return
+	}
+	cp = "/etc/systemd/system/" + s.Config.Name + ".service"
+	return
+}
+
+// systemctl returns the arguments of a systemctl command, talking to the
+// user service manager for user services.
+func (s *systemd) systemctl(args ...string) []string {
+	if s.userService() {
+		return append([]string{"--user"}, args...)
+	}
+	return args
+}
+
+// units returns the systemd unit names of dependencies.
+func units(names []string) []string {
+	for i, name := range names {
+		names[i] = systemdUnit(name)
+	}
+	return names
+}
+
+// socketPath returns the path of the companion socket unit.
+func (s *systemd) socketPath() (string, error) {
+	cp, err := s.configPath()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(cp, ".service") + ".socket", nil
+}
+
+// timerPath returns the path of the timer unit of a scheduled service.
+func (s *systemd) timerPath() (string, error) {
+	cp, err := s.configPath()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(cp, ".service") + ".timer", nil
+}
+
+// unit returns the unit that starts the service: the timer of a scheduled
+// service, or the service itself.
+func (s *systemd) unit() string {
+	if s.scheduled() {
+		return s.Name + ".timer"
+	}
+	return s.Name + ".service"
+}
+
+func (s *systemd) template() *template.Template {
+	return template.Must(template.New("").Funcs(tf).Parse(systemdScript))
+}
+
+// Render returns the unit file and the systemctl commands run by Install.
+func (s *systemd) Render() (*Manifest, error) {
+	confPath, err := s.configPath()
+	if err != nil {
+		return nil, err
+	}
+	path, err := s.execPath()
+	if err != nil {
+		return nil, err
+	}
+
+	args := s.Config.Arguments
+	cmd := strings.Split(path, " ")
+	if len(cmd) > 1 {
+		path = cmd[0]
+		args = append(cmd[1:], args...)
+	}
+
+	def := RestartPolicy{
+		Mode:   RestartAlways,
+		Delay:  120 * time.Second,
+		Burst:  10,
+		Window: 5 * time.Second,
+	}
+	if s.scheduled() {
+		// Oneshot services may only be restarted on failure.
+		def.Mode = RestartNever
+	}
+	restart := s.restartPolicyOr(def)
+	restartMode := string(restart.Mode)
+	if restart.Mode == RestartNever || (s.scheduled() && restart.Mode == RestartAlways) {
+		restartMode = "no"
+	}
+
+	var to = &struct {
+		*Config
+		Arguments         []string
+		Path              string
+		Args              string
+		ReloadSignal      string
+		PIDFile           string
+		StandardOutPath   string
+		StandardErrorPath string
+		StartTimeout      int
+		StopTimeout       int
+		Notify            bool
+		WatchdogSec       int
+		UserService       bool
+		Scheduled         bool
+
+		Restart            string
+		RestartSec         int
+		StartLimitBurst    int
+		StartLimitInterval int
+		SuccessExitStatus  string
+
+		Requires []string
+		Wants    []string
+		After    string
+
+		EnvPath string
+	}{
+		s.Config,
+		args,
+		path,
+		strings.Join(args, " "),
+		s.reloadSignalName(s.i),
+		s.Option.string(optionPIDFile, ""),
+		s.Option.string(optionStandardOutPath, ""),
+		s.Option.string(optionStandardErrorPath, ""),
+		s.timeoutSeconds(optionStartTimeout, optionStartTimeoutDefault),
+		s.timeoutSeconds(optionStopTimeout, optionStopTimeoutDefault),
+		s.Option.bool(optionNotify, optionNotifyDefault),
+		s.timeoutSeconds(optionWatchdogSec, 0),
+		s.userService(),
+		s.scheduled(),
+
+		restartMode,
+		seconds(restart.Delay),
+		restart.Burst,
+		seconds(restart.Window),
+		joinInts(restart.SuccessExitCodes, " "),
+
+		units(s.dependencyNames(dependRequires)),
+		units(s.dependencyNames(dependWants)),
+		strings.Join(units(s.dependencyNames(dependRequires, dependWants, dependAfter)), " "),
+
+		s.envPath(),
+	}
+
+	var buf bytes.Buffer
+	if err := s.template().Execute(&buf, to); err != nil {
+		return nil, err
+	}
+
+	m := &Manifest{
+		Files: []ManifestFile{{Path: confPath, Mode: 0644, Content: buf.Bytes()}},
+	}
+
+	if s.scheduled() {
+		timerPath, err := s.timerPath()
+		if err != nil {
+			return nil, err
+		}
+		var timer = &struct {
+			*Config
+			Interval int
+		}{
+			Config: s.Config,
+		}
+		if d, ok := s.scheduleInterval(); ok {
+			timer.Interval = seconds(d)
+		}
+		var timerBuf bytes.Buffer
+		if err := template.Must(template.New("").Funcs(tf).Parse(systemdTimerScript)).Execute(&timerBuf, timer); err != nil {
+			return nil, err
+		}
+		m.Files = append(m.Files, ManifestFile{Path: timerPath, Mode: 0644, Content: timerBuf.Bytes()})
+	}
+	m.Commands = append(m.Commands, Command{Name: "systemctl", Args: s.systemctl("enable", s.unit())})
+
+	stream := s.Option.strings(optionListenStream, nil)
+	datagram := s.Option.strings(optionListenDatagram, nil)
+	if len(stream) > 0 || len(datagram) > 0 {
+		socketPath, err := s.socketPath()
+		if err != nil {
+			return nil, err
+		}
+		var sockets = &struct {
+			*Config
+			ListenStream   []string
+			ListenDatagram []string
+		}{
+			s.Config,
+			stream,
+			datagram,
+		}
+		var socketBuf bytes.Buffer
+		if err := template.Must(template.New("").Funcs(tf).Parse(systemdSocketScript)).Execute(&socketBuf, sockets); err != nil {
+			return nil, err
+		}
+		m.Files = append(m.Files, ManifestFile{Path: socketPath, Mode: 0644, Content: socketBuf.Bytes()})
+		m.Commands = append(m.Commands, Command{Name: "systemctl", Args: s.systemctl("enable", s.Name+".socket")})
+	}
+
+	m.Commands = append(m.Commands, Command{Name: "systemctl", Args: s.systemctl("daemon-reload")})
+
+	if s.userService() && s.Option.bool(optionLinger, optionLingerDefault) {
+		// Start the user service manager at boot, not at the first login.
+		u, err := user.Current()
+		if err != nil {
+			return nil, err
+		}
+		m.Commands = append([]Command{{Name: "loginctl", Args: []string{"enable-linger", u.Username}}}, m.Commands...)
+	}
+	return m, nil
+}
+
+const systemdScript = `[Unit]
+Description={{.Description}}
+{{range .Requires}}Requires={{.}}
+{{end}}{{range .Wants}}Wants={{.}}
+{{end}}{{if .After}}After={{.After}}
+{{end}}
+[Service]
+{{if .Scheduled}}Type=oneshot
+{{else if .Notify}}Type=notify
+{{end}}StartLimitInterval={{.StartLimitInterval}}
+StartLimitBurst={{.StartLimitBurst}}
+{{if and .StandardErrorPath .StandardOutPath}}
+ExecStart=/bin/sh -c '{{.Path}} {{.Args}} 2>>{{.StandardErrorPath}} 1>>{{.StandardOutPath}}'
+{{else if .StandardOutPath}}
+ExecStart=/bin/sh -c '{{.Path}} {{.Args}} >>{{.StandardOutPath}} 2>&1'
+{{else}}
+ExecStart={{.Path|cmdEscape}}{{range .Arguments}} {{.|cmd}}{{end}}
+{{end}}
+{{if .ChRoot}}RootDirectory={{.ChRoot|cmd}}{{end}}
+{{if .WorkingDirectory}}WorkingDirectory={{.WorkingDirectory|cmdEscape}}{{end}}
+{{if and .UserName (not .UserService)}}User={{.UserName}}{{end}}
+{{if .ReloadSignal}}ExecReload=/bin/kill -{{.ReloadSignal}} "$MAINPID"{{end}}
+{{if .PIDFile}}PIDFile={{.PIDFile|cmd}}{{end}}
+{{if .StartTimeout}}TimeoutStartSec={{.StartTimeout}}{{end}}
+{{if .StopTimeout}}TimeoutStopSec={{.StopTimeout}}{{end}}
+{{if .WatchdogSec}}WatchdogSec={{.WatchdogSec}}{{end}}
+Restart={{.Restart}}
+RestartSec={{.RestartSec}}
+{{if .SuccessExitStatus}}SuccessExitStatus={{.SuccessExitStatus}}
+{{end}}{{range $k, $v := .Envs}}Environment={{printf "%s=%s" $k $v | dquote | specifierEscape}}
+{{end}}EnvironmentFile=-{{.EnvPath}}
+{{if not .Scheduled}}
+[Install]
+WantedBy={{if .UserService}}default.target{{else}}multi-user.target{{end}}
+{{end}}`
+
+// systemdTimerScript is the timer unit of a scheduled service. Persistent
+// calendar timers catch up on runs missed while the system was off.
+const systemdTimerScript = `[Unit]
+Description={{.Description}}
+
+[Timer]
+{{if .Interval}}OnBootSec={{.Interval}}
+OnUnitActiveSec={{.Interval}}
+{{else}}OnCalendar={{.Schedule}}
+Persistent=true
+{{end}}
+[Install]
+WantedBy=timers.target
+`
+
+// systemdSocketScript is the companion socket unit. systemd passes the
+// sockets to the service in the order they are listed, see Listeners.
+const systemdSocketScript = `[Unit]
+Description={{.Description}}
+
+[Socket]
+{{range .ListenStream}}ListenStream={{.}}
+{{end}}{{range .ListenDatagram}}ListenDatagram={{.}}
+{{end}}
+[Install]
+WantedBy=sockets.target
+`
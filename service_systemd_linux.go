@@ -8,13 +8,11 @@ import (
 	"errors"
 	"fmt"
 	"os"
-	"os/exec"
-	"os/signal"
-	"regexp"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"syscall"
-	"text/template"
+	"time"
 )
 
 func isSystemd() bool {
@@ -24,11 +22,6 @@ func isSystemd() bool {
 	return false
 }
 
-type systemd struct {
-	i Interface
-	*Config
-}
-
 func newSystemdService(i Interface, c *Config) (Service, error) {
 	s := &systemd{
 		i:      i,
@@ -45,87 +38,89 @@ func (s *systemd) String() string {
 	return s.Name
 }
 
-// Systemd services should be supported, but are not currently.
-var errNoUserServiceSystemd = errors.New("User services are not supported on systemd.")
-
-func (s *systemd) configPath() (cp string, err error) {
-	cp = "/etc/systemd/system/" + s.Config.Name + ".service"
-	return
+func validateSystemdConfig(c *Config) []error {
+	var errs []error
+	if c.Option.bool(optionUserService, optionUserServiceDefault) {
+		if len(c.UserName) > 0 {
+			errs = append(errs, errors.New("Config.UserName is not supported for systemd user services."))
+		}
+	} else if c.Option.bool(optionLinger, optionLingerDefault) {
+		errs = append(errs, errors.New("Option Linger requires the UserService option."))
+	}
+	if c.scheduled() {
+		for _, name := range []string{optionNotify, optionWatchdogSec} {
+			if _, found := c.Option[name]; found {
+				errs = append(errs, fmt.Errorf("Option %s is not supported for scheduled services.", name))
+			}
+		}
+	}
+	return errs
 }
 
-func (s *systemd) template() *template.Template {
-	return template.Must(template.New("").Funcs(tf).Parse(systemdScript))
+func (s *systemd) Install() error {
+	_, err := s.install()
+	return err
 }
 
-func (s *systemd) Install() error {
+func (s *systemd) install() (*Manifest, error) {
+	if err := s.Validate(system); err != nil {
+		return nil, err
+	}
 	confPath, err := s.configPath()
 	if err != nil {
-		return err
+		return nil, err
 	}
-	_, err = os.Stat(confPath)
+	root := s.installRoot()
+	_, err = os.Stat(filepath.Join(root, confPath))
 	if err == nil {
-		return fmt.Errorf("Init already exists: %s", confPath)
+		return nil, fmt.Errorf("Init already exists: %s", confPath)
 	}
 
-	f, err := os.Create(confPath)
+	m, err := s.Render()
 	if err != nil {
-		return err
+		return nil, err
 	}
-	defer f.Close()
+	return s.apply(m)
+}
 
-	path, err := s.execPath()
+func (s *systemd) Uninstall() error {
+	// The timer would start the removed service until the next boot.
+	if s.scheduled() {
+		s.run("systemctl", s.systemctl("stop", s.unit())...)
+	}
+	err := s.run("systemctl", s.systemctl("disable", s.unit())...)
 	if err != nil {
 		return err
 	}
-
-	cmd := strings.Split(path, " ")
-	if len(cmd) > 1 {
-		path = cmd[0]
-		s.Config.Arguments = append(cmd[1:], s.Config.Arguments...)
-	}
-
-	var to = &struct {
-		*Config
-		Path              string
-		Args              string
-		ReloadSignal      string
-		PIDFile           string
-		StandardOutPath   string
-		StandardErrorPath string
-	}{
-		s.Config,
-		path,
-		strings.Join(s.Config.Arguments, " "),
-		s.Option.string(optionReloadSignal, ""),
-		s.Option.string(optionPIDFile, ""),
-		s.Option.string(optionStandardOutPath, ""),
-		s.Option.string(optionStandardErrorPath, ""),
-	}
-
-	err = s.template().Execute(f, to)
+	cp, err := s.configPath()
 	if err != nil {
 		return err
 	}
-
-	err = run("systemctl", "enable", s.Name+".service")
-	if err != nil {
+	if err := os.Remove(cp); err != nil {
 		return err
 	}
-	return run("systemctl", "daemon-reload")
-}
 
-func (s *systemd) Uninstall() error {
-	err := run("systemctl", "disable", s.Name+".service")
+	tp, err := s.timerPath()
 	if err != nil {
 		return err
 	}
-	cp, err := s.configPath()
-	if err != nil {
+	if err := os.Remove(tp); err != nil && !os.IsNotExist(err) {
 		return err
 	}
-	if err := os.Remove(cp); err != nil {
+
+	sp, err := s.socketPath()
+	if err != nil {
 		return err
 	}
+	if _, err := os.Stat(sp); err == nil {
+		s.run("systemctl", s.systemctl("stop", s.Name+".socket")...)
+		if err := s.run("systemctl", s.systemctl("disable", s.Name+".socket")...); err != nil {
+			return err
+		}
+		if err := os.Remove(sp); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -141,30 +136,41 @@ func (s *systemd) SystemLogger(errs chan<- error) (Logger, error) {
 }
 
 func (s *systemd) Run() (err error) {
-	err = s.i.Start(s)
+	err = s.startProgram(s.i, s)
 	if err != nil {
 		return err
 	}
+	s.notify("READY=1")
 
-	s.Option.funcSingle(optionRunWait, func() {
-		var sigChan = make(chan os.Signal, 3)
-		signal.Notify(sigChan, syscall.SIGTERM, os.Interrupt)
-		<-sigChan
+	stopWatchdog := make(chan struct{})
+	if interval := watchdogInterval(); interval > 0 {
+		l, _ := s.Logger(nil)
+		go s.watchdog(interval, stopWatchdog, l)
+	}
+
+	s.runWait(func() {
+		s.waitForSignal(s.i, s, syscall.SIGTERM, os.Interrupt)
 	})()
 
-	return s.i.Stop(s)
+	s.notify("STOPPING=1")
+	close(stopWatchdog)
+	return s.stopProgram(s.i, s)
 }
 
 func (s *systemd) Start() error {
-	return run("systemctl", "start", s.Name+".service")
+	return s.run("systemctl", s.systemctl("start", s.unit())...)
 }
 
 func (s *systemd) Stop() error {
-	return run("systemctl", "stop", s.Name+".service")
+	return s.run("systemctl", s.systemctl("stop", s.unit())...)
 }
 
 func (s *systemd) Restart() error {
-	return run("systemctl", "restart", s.Name+".service")
+	return s.run("systemctl", s.systemctl("restart", s.unit())...)
+}
+
+func (s *systemd) Reload() error {
+	return s.run("systemctl", s.systemctl("reload", s.Name+".service")...)
 }
 
 func (s *systemd) PID() (int, error) {
@@ -172,63 +178,112 @@ func (s *systemd) PID() (int, error) {
 }
 
 func (s *systemd) checkRunning() (int, error) {
-	out, err := exec.Command("systemctl", "status", s.Name+".service").Output()
+	props, err := s.show(s.Name+".service", "ActiveState", "MainPID")
 	if err != nil {
 		return -1, err
 	}
-	if matched, err := regexp.MatchString("Active: active", string(out)); err == nil && matched {
-		reg := regexp.MustCompile("Main PID: ([0-9]+)")
-		data := reg.FindStringSubmatch(string(out))
-		if len(data) > 1 {
-			return strconv.Atoi(data[1])
+	switch props["ActiveState"] {
+	case "active", "reloading":
+		if pid, err := strconv.Atoi(props["MainPID"]); err == nil && pid > 0 {
+			return pid, nil
 		}
-		return -1, nil
+		// Such as oneshot services and forking services without PIDFile.
+		return -1, fmt.Errorf("systemd reports no main PID for %s.", s.Name)
 	}
 	return -1, ErrServiceIsNotRunning
 }
 
-func (s *systemd) Status() (string, error) {
-	pid, err := s.checkRunning()
+// show returns the given properties of a unit from systemctl show, which
+// unlike systemctl status is meant to be parsed.
+func (s *systemd) show(unit string, props ...string) (map[string]string, error) {
+	out, err := s.runWithOutput("systemctl", s.systemctl("show", "-p", strings.Join(props, ","), unit)...)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	return "running (pid: " + strconv.Itoa(pid) + ")", nil
+	return systemdProperties(out), nil
 }
 
-func (s *systemd) Update() error {
-	if err := run("systemctl", "daemon-reload"); err != nil {
-		return err
+func (s *systemd) Status() (ServiceStatus, error) {
+	status := ServiceStatus{Backend: systemdName}
+	props, err := s.show(s.Name+".service", "LoadState", "ActiveState", "SubState", "MainPID", "ExecMainStartTimestamp", "NRestarts", "Result", "ExecMainStatus")
+	if err != nil {
+		return status, err
+	}
+	status.LoadState = props["LoadState"]
+	status.SubState = props["SubState"]
+	status.Result = props["Result"]
+	if status.LoadState == "not-found" {
+		status.State = StateNotInstalled
+		return status, nil
 	}
 
-	if err := run("systemctl", "enable", s.Name+".service"); err != nil {
-		return err
+	switch props["ActiveState"] {
+	case "active", "reloading", "deactivating":
+		status.State = StateRunning
+	case "activating":
+		status.State = StateActivating
+	case "inactive":
+		status.State = StateStopped
+	case "failed":
+		status.State = StateFailed
+	default:
+		status.State = StateUnknown
+	}
+	// MainPID is 0 and the other values describe the last run when the
+	// service is not running.
+	status.PID, _ = strconv.Atoi(props["MainPID"])
+	status.StartTime = parseSystemdTime(props["ExecMainStartTimestamp"])
+	status.RestartCount, _ = strconv.Atoi(props["NRestarts"])
+	status.ExitCode, _ = strconv.Atoi(props["ExecMainStatus"])
+
+	if s.scheduled() {
+		props, err := s.show(s.unit(), "LastTriggerUSec", "NextElapseUSecRealtime")
+		if err != nil {
+			return status, err
+		}
+		status.LastRun = parseSystemdTime(props["LastTriggerUSec"])
+		status.NextRun = parseSystemdTime(props["NextElapseUSecRealtime"])
+		// Interval timers only report their next run relative to boot.
+		if d, ok := s.scheduleInterval(); ok && status.NextRun.IsZero() && !status.LastRun.IsZero() {
+			status.NextRun = status.LastRun.Add(d)
+		}
 	}
+	return status, nil
+}
 
-	return nil
+// systemdProperties parses the output of systemctl show.
+func systemdProperties(out []byte) map[string]string {
+	props := make(map[string]string)
+	for _, line := range strings.Split(string(out), "\n") {
+		if pos := strings.Index(line, "="); pos != -1 {
+			props[line[:pos]] = strings.TrimSpace(line[pos+1:])
+		}
+	}
+	return props
 }
 
-const systemdScript = `[Unit]
-Description={{.Description}}
-
-[Service]
-StartLimitInterval=5
-StartLimitBurst=10
-{{if and .StandardErrorPath .StandardOutPath}}
-ExecStart=/bin/sh -c '{{.Path}} {{.Args}} 2>>{{.StandardErrorPath}} 1>>{{.StandardOutPath}}'
-{{else if .StandardOutPath}}
-ExecStart=/bin/sh -c '{{.Path}} {{.Args}} >>{{.StandardOutPath}} 2>&1'
-{{else}}
-ExecStart={{.Path|cmdEscape}}{{range .Arguments}} {{.|cmd}}{{end}}
-{{end}}
-{{if .ChRoot}}RootDirectory={{.ChRoot|cmd}}{{end}}
-{{if .WorkingDirectory}}WorkingDirectory={{.WorkingDirectory|cmdEscape}}{{end}}
-{{if .UserName}}User={{.UserName}}{{end}}
-{{if .ReloadSignal}}ExecReload=/bin/kill -{{.ReloadSignal}} "$MAINPID"{{end}}
-{{if .PIDFile}}PIDFile={{.PIDFile|cmd}}{{end}}
-Restart=always
-RestartSec=120
-EnvironmentFile=-/etc/sysconfig/{{.Name}}
-
-[Install]
-WantedBy=multi-user.target
-`
+// parseSystemdTime parses a timestamp of systemctl show, such as
+// "Thu 2017-05-04 02:00:00 UTC". Empty and n/a values are the zero time.
+func parseSystemdTime(v string) time.Time {
+	t, err := time.ParseInLocation("Mon 2006-01-02 15:04:05 MST", v, time.Local)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+// Update rewrites the installed files that differ from what Install would
+// write. See InstallOrUpdate.
+func (s *systemd) Update() error {
+	return updateService(s)
+}
+
+// GetEnv returns the variables of /etc/sysconfig/<name>. See EnvManager.
+func (s *systemd) GetEnv() (map[string]string, error) {
+	return readEnvFile(s.envPath())
+}
+
+// SetEnv replaces the variables of /etc/sysconfig/<name>. See EnvManager.
+func (s *systemd) SetEnv(env map[string]string) error {
+	return writeEnvFile(s.envPath(), env)
+}
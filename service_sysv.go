@@ -0,0 +1,396 @@
+// Copyright 2015 Daniel Theophanes.
+// Use of this source code is governed by a zlib-style
+// license that can be found in the LICENSE file.
+
+package service
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+type sysv struct {
+	i Interface
+	*Config
+
+	// offline renders the runlevel symlinks without looking for the
+	// registration tools of the host.
+	offline bool
+}
+
+var errNoUserServiceSystemV = errors.New("User services are not supported on SystemV.")
+
+func (s *sysv) configPath() (cp string, err error) {
+	if s.Option.bool(optionUserService, optionUserServiceDefault) {
+		err = errNoUserServiceSystemV
+		return
+	}
+	cp = "/etc/init.d/" + s.Config.Name
+	return
+}
+
+// sysvTools are the tools registering init scripts in the runlevels, in
+// order of preference.
+var sysvTools = []string{"update-rc.d", "chkconfig", "insserv"}
+
+// rcTool returns the path of the tool registering init scripts on the host,
+// or "" to create the runlevel symlinks. Staged installs use symlinks.
+func (s *sysv) rcTool() string {
+	if s.offline || len(s.installRoot()) > 0 {
+		return ""
+	}
+	for _, tool := range sysvTools {
+		// The tools are in sbin, which may not be in the PATH of users.
+		for _, path := range []string{tool, "/usr/sbin/" + tool, "/sbin/" + tool} {
+			if path, err := exec.LookPath(path); err == nil {
+				return path
+			}
+		}
+	}
+	return ""
+}
+
+// priorities returns the start and kill sequence numbers of the runlevel
+// links. The service starts after and stops before the dependencies that
+// have links in /etc/rc2.d and /etc/rc0.d.
+func (s *sysv) priorities() (start, stop int) {
+	start, stop = 50, 2
+	for _, name := range s.dependencyNames(dependRequires, dependWants, dependAfter) {
+		name = jobName(name)
+		if len(name) == 0 {
+			continue
+		}
+		if p, found := rcPriority("/etc/rc2.d/S", name); found && p >= start {
+			start = p + 1
+		}
+		if p, found := rcPriority("/etc/rc0.d/K", name); found && p <= stop {
+			stop = p - 1
+		}
+	}
+	if start > 99 {
+		start = 99
+	}
+	if stop < 0 {
+		stop = 0
+	}
+	return start, stop
+}
+
+// rcPriority returns the sequence number of the runlevel link of the named
+// init script starting with prefix.
+func rcPriority(prefix, name string) (int, bool) {
+	links, _ := filepath.Glob(prefix + "[0-9][0-9]" + name)
+	if len(links) == 0 {
+		return 0, false
+	}
+	p, err := strconv.Atoi(links[0][len(prefix) : len(prefix)+2])
+	return p, err == nil
+}
+
+// pidFile returns the path of the file the init script writes the PID of the
+// program to, the PIDFile option or /var/run/<name>.pid.
+func (s *sysv) pidFile() string {
+	return s.Option.string(optionPIDFile, "/var/run/"+s.Name+".pid")
+}
+
+// logPaths returns the files the init script appends the output of the
+// program to. stderr is empty if the errors go to stdout, as they do when
+// only the StandardOutPath option is set.
+func (s *sysv) logPaths() (stdout, stderr string) {
+	stdout = s.Option.string(optionStandardOutPath, "")
+	stderr = s.Option.string(optionStandardErrorPath, "")
+	switch {
+	case len(stdout) == 0 && len(stderr) == 0:
+		return "/var/log/" + s.Name + ".log", "/var/log/" + s.Name + ".err"
+	case len(stdout) == 0:
+		stdout = "/var/log/" + s.Name + ".log"
+	}
+	return stdout, stderr
+}
+
+func (s *sysv) template() *template.Template {
+	return template.Must(template.New("").Funcs(tf).Parse(sysvScript))
+}
+
+// lsbNames returns the LSB header value of dependencies.
+func lsbNames(names []string) string {
+	for i, name := range names {
+		names[i] = lsbName(name)
+	}
+	return strings.Join(names, " ")
+}
+
+// Render returns the init script created by Install and the commands of the
+// host registering it in the runlevels, or the runlevel symlinks if the host
+// has none of the sysvTools.
+func (s *sysv) Render() (*Manifest, error) {
+	confPath, err := s.configPath()
+	if err != nil {
+		return nil, err
+	}
+	path, err := s.execPath()
+	if err != nil {
+		return nil, err
+	}
+
+	args := s.Arguments
+	if cmd := strings.Split(path, " "); len(cmd) > 1 {
+		path = cmd[0]
+		args = append(cmd[1:], args...)
+	}
+	user := strings.SplitN(s.UserName, ":", 2)
+	if len(user) == 1 {
+		user = append(user, "")
+	}
+	stdout, stderr := s.logPaths()
+
+	start, stop := s.priorities()
+
+	var to = &struct {
+		*Config
+		Path         string
+		Args         []string
+		StopTimeout  int
+		ReloadSignal string
+
+		User      string
+		Group     string
+		PIDFile   string
+		StdoutLog string
+		StderrLog string
+
+		RequiredStart string
+		ShouldStart   string
+
+		Start string
+		Stop  string
+	}{
+		s.Config,
+		path,
+		args,
+		s.timeoutSeconds(optionStopTimeout, optionStopTimeoutDefault),
+		s.reloadSignalName(s.i),
+
+		user[0],
+		user[1],
+		s.pidFile(),
+		stdout,
+		stderr,
+
+		lsbNames(s.dependencyNames(dependRequires)),
+		lsbNames(s.dependencyNames(dependWants, dependAfter)),
+
+		fmt.Sprintf("%02d", start),
+		fmt.Sprintf("%02d", stop),
+	}
+
+	var buf bytes.Buffer
+	if err := s.template().Execute(&buf, to); err != nil {
+		return nil, err
+	}
+
+	m := &Manifest{
+		Files: []ManifestFile{{Path: confPath, Mode: 0755, Content: buf.Bytes()}},
+	}
+	switch tool := s.rcTool(); filepath.Base(tool) {
+	case "update-rc.d":
+		// Links of an older install are removed to apply new priorities.
+		m.Commands = []Command{
+			{Name: tool, Args: []string{"-f", s.Name, "remove"}},
+			{Name: tool, Args: []string{s.Name, "defaults", to.Start, to.Stop}},
+		}
+	case "chkconfig":
+		m.Commands = []Command{{Name: tool, Args: []string{"--add", s.Name}}}
+	case "insserv":
+		m.Commands = []Command{{Name: tool, Args: []string{s.Name}}}
+	default:
+		for _, i := range [...]string{"2", "3", "4", "5"} {
+			m.Symlinks = append(m.Symlinks, ManifestSymlink{Path: "/etc/rc" + i + ".d/S" + to.Start + s.Name, Target: confPath})
+		}
+		for _, i := range [...]string{"0", "1", "6"} {
+			m.Symlinks = append(m.Symlinks, ManifestSymlink{Path: "/etc/rc" + i + ".d/K" + to.Stop + s.Name, Target: confPath})
+		}
+	}
+	return m, nil
+}
+
+const sysvScript = `#!/bin/sh
+# For RedHat and cousins:
+# chkconfig: 2345 {{.Start}} {{.Stop}}
+# description: {{.Description}}
+# processname: {{.Path}}
+# pidfile: {{.PIDFile}}
+
+### BEGIN INIT INFO
+# Provides:          {{.Name}}
+# Required-Start:{{if .RequiredStart}}    {{.RequiredStart}}{{end}}
+# Required-Stop:{{if .RequiredStart}}     {{.RequiredStart}}{{end}}
+{{if .ShouldStart}}# Should-Start:      {{.ShouldStart}}
+# Should-Stop:       {{.ShouldStart}}
+{{end}}# Default-Start:     2 3 4 5
+# Default-Stop:      0 1 6
+# Short-Description: {{.DisplayName}}
+# Description:       {{.Description}}
+### END INIT INFO
+
+name={{.Name|shquote}}
+user={{.User|shquote}}
+group={{.Group|shquote}}
+pid_file={{.PIDFile|shquote}}
+stdout_log={{.StdoutLog|shquote}}
+{{if .StderrLog}}stderr_log={{.StderrLog|shquote}}
+{{end}}
+{{range $k, $v := .Envs}}export {{$k}}={{$v | shquote}}
+{{end}}set -a
+[ -e /etc/sysconfig/$name ] && . /etc/sysconfig/$name
+set +a
+
+get_pid() {
+    cat "$pid_file" 2> /dev/null
+}
+
+# Users querying the status may not signal the processes of other users.
+is_running() {
+    pid=$(get_pid)
+    [ -n "$pid" ] && { kill -0 "$pid" 2> /dev/null || [ -d "/proc/$pid" ]; }
+}
+
+# daemonize runs the command as $user. The shell writes its PID to file
+# descriptor 3 and then replaces itself with the command.
+daemonize() {
+    script='echo $$ >&3; exec 3>&-; exec "$0" "$@"'
+{{- if .ChRoot}}
+    # The command and the working directory are paths in the chroot.
+    work_dir={{if .WorkingDirectory}}{{.WorkingDirectory|shquote}}{{else}}/{{end}} chroot ${user:+--userspec="$user${group:+:$group}"} {{.ChRoot|shquote}} /bin/sh -c 'cd "$work_dir" || exit 1; '"$script" "$@"
+}
+{{- else}}
+    if [ -z "$user" ]; then
+        /bin/sh -c "$script" "$@"
+    elif command -v runuser > /dev/null 2>&1; then
+        runuser -u "$user" ${group:+-g "$group"} -- /bin/sh -c "$script" "$@"
+    elif command -v start-stop-daemon > /dev/null 2>&1; then
+        start-stop-daemon --start --quiet --pidfile "$pid_file" --chuid "$user${group:+:$group}" --startas /bin/sh -- -c "$script" "$@"
+    else
+        su -s /bin/sh -c "$script" -- "$user" "$@"
+    fi
+}
+{{- end}}
+
+do_start() {
+    if is_running; then
+        echo "Already started"
+        return 0
+    fi
+    echo "Starting $name"
+    {{if and .WorkingDirectory (not .ChRoot)}}cd {{.WorkingDirectory|shquote}} || return 1
+    {{end -}}
+    daemonize {{.Path|shquote}}{{range .Args}} {{.|shquote}}{{end}} >> "$stdout_log" {{if .StderrLog}}2>> "$stderr_log"{{else}}2>&1{{end}} 3> "$pid_file" &
+    sleep 1
+    if ! is_running; then
+        echo "Unable to start, see $stdout_log{{if .StderrLog}} and $stderr_log{{end}}"
+        return 1
+    fi
+}
+
+do_stop() {
+    if ! is_running; then
+        echo "Not running"
+        rm -f "$pid_file"
+        return 0
+    fi
+    echo -n "Stopping $name.."
+    kill $(get_pid)
+    for i in $(seq 1 {{if .StopTimeout}}{{.StopTimeout}}{{else}}10{{end}})
+    do
+        if ! is_running; then
+            break
+        fi
+        echo -n "."
+        sleep 1
+    done
+    echo
+    if is_running; then
+        echo "Not stopped; may still be shutting down or shutdown may have failed"
+        return 1
+    fi
+    echo "Stopped"
+    rm -f "$pid_file"
+}
+
+do_restart() {
+    if ! do_stop; then
+        echo "Unable to stop, will not attempt to start"
+        return 1
+    fi
+    do_start
+}
+{{if .ReloadSignal}}
+do_reload() {
+    if ! is_running; then
+        echo "Not running"
+        return 7
+    fi
+    echo "Reloading $name"
+    kill -{{.ReloadSignal}} $(get_pid)
+}
+{{end}}
+# The actions and exit codes are those of the LSB init scripts.
+case "$1" in
+    start)
+        do_start || exit 1
+    ;;
+    stop)
+        do_stop || exit 1
+    ;;
+    restart)
+        do_restart || exit 1
+    ;;
+    try-restart|condrestart)
+        if is_running; then
+            do_restart || exit 1
+        else
+            echo "Not running"
+        fi
+    ;;
+{{- if .ReloadSignal}}
+    reload|force-reload)
+        do_reload || exit $?
+    ;;
+{{- else}}
+    reload)
+        echo "Reload is not supported"
+        exit 3
+    ;;
+    force-reload)
+        if is_running; then
+            do_restart || exit 1
+        else
+            echo "Not running"
+            exit 7
+        fi
+    ;;
+{{- end}}
+    status)
+        if is_running; then
+            echo "$name is running"
+        elif [ -s "$pid_file" ]; then
+            echo "$name is dead but the pid file exists"
+            exit 1
+        else
+            echo "$name is stopped"
+            exit 3
+        fi
+    ;;
+    *)
+    echo "Usage: $0 {start|stop|restart|try-restart|reload|force-reload|status}"
+    exit 2
+    ;;
+esac
+exit 0
+`
@@ -5,24 +5,16 @@
 package service
 
 import (
-	"errors"
 	"fmt"
 	"os"
-	"os/exec"
-	"os/signal"
-	"regexp"
-	"strconv"
+	"path/filepath"
 	"syscall"
-	"text/template"
-	"time"
 )
 
-type sysv struct {
-	i Interface
-	*Config
-}
-
 func newSystemVService(i Interface, c *Config) (Service, error) {
+	if c.scheduled() {
+		return newCronService(i, c, sysvName)
+	}
 	s := &sysv{
 		i:      i,
 		Config: c,
@@ -38,80 +30,69 @@ func (s *sysv) String() string {
 	return s.Name
 }
 
-var errNoUserServiceSystemV = errors.New("User services are not supported on SystemV.")
-
-func (s *sysv) configPath() (cp string, err error) {
-	if s.Option.bool(optionUserService, optionUserServiceDefault) {
-		err = errNoUserServiceSystemV
-		return
+func validateSystemVConfig(c *Config) []error {
+	var errs []error
+	if c.Option.bool(optionUserService, optionUserServiceDefault) {
+		errs = append(errs, errNoUserServiceSystemV)
 	}
-	cp = "/etc/init.d/" + s.Config.Name
-	return
-}
-func (s *sysv) template() *template.Template {
-	return template.Must(template.New("").Funcs(tf).Parse(sysvScript))
+	errs = append(errs, validateCronSchedule(c)...)
+	return errs
 }
 
 func (s *sysv) Install() error {
+	_, err := s.install()
+	return err
+}
+
+func (s *sysv) install() (*Manifest, error) {
+	if err := s.Validate(system); err != nil {
+		return nil, err
+	}
 	confPath, err := s.configPath()
 	if err != nil {
-		return err
+		return nil, err
 	}
-	_, err = os.Stat(confPath)
+	root := s.installRoot()
+	_, err = os.Stat(filepath.Join(root, confPath))
 	if err == nil {
-		return fmt.Errorf("Init already exists: %s", confPath)
+		return nil, fmt.Errorf("Init already exists: %s", confPath)
 	}
 
-	f, err := os.Create(confPath)
+	m, err := s.Render()
 	if err != nil {
-		return err
+		return nil, err
 	}
-	defer f.Close()
+	return s.apply(m)
+}
 
-	path, err := s.execPath()
+// Uninstall unregisters the init script from the runlevels, removes the
+// links left over by older installs and then the init script.
+func (s *sysv) Uninstall() error {
+	cp, err := s.configPath()
 	if err != nil {
 		return err
 	}
-
-	var to = &struct {
-		*Config
-		Path string
-	}{
-		s.Config,
-		path,
+	switch tool := s.rcTool(); filepath.Base(tool) {
+	case "update-rc.d":
+		err = s.run(tool, "-f", s.Name, "remove")
+	case "chkconfig":
+		err = s.run(tool, "--del", s.Name)
+	case "insserv":
+		err = s.run(tool, "-r", s.Name)
 	}
-
-	err = s.template().Execute(f, to)
 	if err != nil {
 		return err
 	}
 
-	if err = os.Chmod(confPath, 0755); err != nil {
-		return err
-	}
-	for _, i := range [...]string{"2", "3", "4", "5"} {
-		if err = os.Symlink(confPath, "/etc/rc"+i+".d/S50"+s.Name); err != nil {
-			continue
+	links, _ := filepath.Glob("/etc/rc[0-6S].d/[SK][0-9][0-9]" + s.Name)
+	for _, link := range links {
+		if target, err := os.Readlink(link); err == nil && filepath.Base(target) == s.Name {
+			if err := os.Remove(link); err != nil {
+				return err
+			}
 		}
 	}
-	for _, i := range [...]string{"0", "1", "6"} {
-		if err = os.Symlink(confPath, "/etc/rc"+i+".d/K02"+s.Name); err != nil {
-			continue
-		}
-	}
-
-	return nil
-}
-
-func (s *sysv) Uninstall() error {
-	cp, err := s.configPath()
-	if err != nil {
-		return err
-	}
-	if err := os.Remove(cp); err != nil {
-		return err
-	}
-	return nil
+	return os.Remove(cp)
 }
 
 func (s *sysv) Logger(errs chan<- error) (Logger, error) {
@@ -126,56 +107,43 @@ func (s *sysv) SystemLogger(errs chan<- error) (Logger, error) {
 }
 
 func (s *sysv) Run() (err error) {
-	err = s.i.Start(s)
+	err = s.startProgram(s.i, s)
 	if err != nil {
 		return err
 	}
 
 	s.Option.funcSingle(optionRunWait, func() {
-		var sigChan = make(chan os.Signal, 3)
-		signal.Notify(sigChan, syscall.SIGTERM, os.Interrupt)
-		<-sigChan
+		s.waitForSignal(s.i, s, syscall.SIGTERM, os.Interrupt)
 	})()
 
-	return s.i.Stop(s)
+	return s.stopProgram(s.i, s)
 }
 
 func (s *sysv) Start() error {
-	return run("service", s.Name, "start")
+	return s.run("service", s.Name, "start")
 }
 
 func (s *sysv) Stop() error {
-	return run("service", s.Name, "stop")
+	return s.run("service", s.Name, "stop")
 }
 
 func (s *sysv) Restart() error {
-	err := s.Stop()
-	if err != nil {
-		return err
-	}
-	time.Sleep(50 * time.Millisecond)
-	return s.Start()
+	return s.run("service", s.Name, "restart")
+}
+
+func (s *sysv) Reload() error {
+	return s.run("service", s.Name, "reload")
 }
 
+// Update rewrites the installed files that differ from what Install would
+// write. See InstallOrUpdate.
 func (s *sysv) Update() error {
-	return nil
+	return updateService(s)
 }
 
-// Check service is running
+// checkRunning reads the PID file written by the init script.
 func (s *sysv) checkRunning() (int, error) {
-	output, err := exec.Command("service", s.Name, "status").Output()
-	if err != nil {
-		return -1, err
-	}
-	if matched, err := regexp.MatchString(s.Name, string(output)); err == nil && matched {
-		reg := regexp.MustCompile("pid  ([0-9]+)")
-		data := reg.FindStringSubmatch(string(output))
-		if len(data) > 1 {
-			return strconv.Atoi(data[1])
-		}
-		return -1, nil
-	}
-	return -1, ErrServiceIsNotRunning
+	return readPIDFile(s.pidFile())
 }
 
 func (s *sysv) PID() (int, error) {
@@ -194,108 +162,48 @@ func (s *sysv) isRunning() (int, bool) {
 }
 
 // Status - Get service status
-func (s *sysv) Status() (string, error) {
-	pid, err := s.checkRunning()
+func (s *sysv) Status() (ServiceStatus, error) {
+	status := ServiceStatus{Backend: sysvName}
+	cp, err := s.configPath()
 	if err != nil {
-		return "", err
+		return status, err
+	}
+	if _, err := os.Stat(cp); os.IsNotExist(err) {
+		status.State = StateNotInstalled
+		return status, nil
 	}
-	return "running (pid: " + strconv.Itoa(pid) + ")", nil
-}
-
-const sysvScript = `#!/bin/sh
-# For RedHat and cousins:
-# chkconfig: - 99 01
-# description: {{.Description}}
-# processname: {{.Path}}
-
-### BEGIN INIT INFO
-# Provides:          {{.Path}}
-# Required-Start:
-# Required-Stop:
-# Default-Start:     2 3 4 5
-# Default-Stop:      0 1 6
-# Short-Description: {{.DisplayName}}
-# Description:       {{.Description}}
-### END INIT INFO
-
-cmd="{{.Path}}{{range .Arguments}} {{.|cmd}}{{end}}"
-
-name=$(basename $(readlink -f $0))
-pid_file="/var/run/$name.pid"
-stdout_log="/var/log/$name.log"
-stderr_log="/var/log/$name.err"
-
-[ -e /etc/sysconfig/$name ] && . /etc/sysconfig/$name
 
-get_pid() {
-    cat "$pid_file"
+	_, err = s.runWithOutput("service", s.Name, "status")
+	if err != nil {
+		code, ok := exitStatus(err)
+		if !ok {
+			return status, err
+		}
+		// LSB scripts exit with 1 or 2 when the program died, 3 when it
+		// is stopped and 4 when the status is unknown.
+		switch code {
+		case 1, 2:
+			status.State = StateFailed
+		case 4:
+			status.State = StateUnknown
+		default:
+			status.State = StateStopped
+		}
+		return status, nil
+	}
+	status.State = StateRunning
+	if pid, err := s.checkRunning(); err == nil && pid > 0 {
+		status.PID = pid
+	}
+	return status, nil
 }
 
-is_running() {
-    [ -f "$pid_file" ] && ps $(get_pid) > /dev/null 2>&1
+// GetEnv returns the variables of /etc/sysconfig/<name>. See EnvManager.
+func (s *sysv) GetEnv() (map[string]string, error) {
+	return readEnvFile(s.envPath())
 }
 
-case "$1" in
-    start)
-        if is_running; then
-            echo "Already started"
-        else
-            echo "Starting $name"
-            {{if .WorkingDirectory}}cd '{{.WorkingDirectory}}'{{end}}
-            $cmd >> "$stdout_log" 2>> "$stderr_log" &
-            echo $! > "$pid_file"
-            if ! is_running; then
-                echo "Unable to start, see $stdout_log and $stderr_log"
-                exit 1
-            fi
-        fi
-    ;;
-    stop)
-        if is_running; then
-            echo -n "Stopping $name.."
-            kill $(get_pid)
-            for i in $(seq 1 10)
-            do
-                if ! is_running; then
-                    break
-                fi
-                echo -n "."
-                sleep 1
-            done
-            echo
-            if is_running; then
-                echo "Not stopped; may still be shutting down or shutdown may have failed"
-                exit 1
-            else
-                echo "Stopped"
-                if [ -f "$pid_file" ]; then
-                    rm "$pid_file"
-                fi
-            fi
-        else
-            echo "Not running"
-        fi
-    ;;
-    restart)
-        $0 stop
-        if is_running; then
-            echo "Unable to stop, will not attempt to start"
-            exit 1
-        fi
-        $0 start
-    ;;
-    status)
-        if is_running; then
-            echo "Running"
-        else
-            echo "Stopped"
-            exit 1
-        fi
-    ;;
-    *)
-    echo "Usage: $0 {start|stop|restart|status}"
-    exit 1
-    ;;
-esac
-exit 0
-`
+// SetEnv replaces the variables of /etc/sysconfig/<name>. See EnvManager.
+func (s *sysv) SetEnv(env map[string]string) error {
+	return writeEnvFile(s.envPath(), env)
+}
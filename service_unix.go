@@ -7,10 +7,18 @@
 package service
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"log/syslog"
+	"os"
 	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
 )
 
 func newSysLogger(name string, errs chan<- error) (Logger, error) {
@@ -52,42 +60,355 @@ func (s sysLogger) Infof(format string, a ...interface{}) error {
 	return s.send(s.Writer.Info(fmt.Sprintf(format, a...)))
 }
 
-func run(command string, arguments ...string) error {
-	cmd := exec.Command(command, arguments...)
+// exitStatus returns the exit code of a command that ran but failed.
+// The bool is false if err does not come from a non-zero exit status.
+func exitStatus(err error) (int, bool) {
+	if e, is := err.(interface{ ExitCode() int }); is {
+		return e.ExitCode(), true
+	}
+	if exitErr, is := err.(*exec.ExitError); is {
+		if ws, is := exitErr.Sys().(syscall.WaitStatus); is {
+			return ws.ExitStatus(), true
+		}
+	}
+	return 0, false
+}
+
+// readPIDFile returns the PID in the file at path if the process exists.
+func readPIDFile(path string) (int, error) {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return -1, ErrServiceIsNotRunning
+	}
+	if err != nil {
+		return -1, err
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil || pid <= 0 {
+		return -1, ErrServiceIsNotRunning
+	}
+	// EPERM is returned for the processes of other users.
+	if err := syscall.Kill(pid, 0); err != nil && err != syscall.EPERM {
+		return -1, ErrServiceIsNotRunning
+	}
+	return pid, nil
+}
+
+var errNoReloadSignal = errors.New("Reload requires the ReloadSignal option or a Reloader program.")
+
+var signalNames = map[string]syscall.Signal{
+	"HUP":  syscall.SIGHUP,
+	"INT":  syscall.SIGINT,
+	"QUIT": syscall.SIGQUIT,
+	"TERM": syscall.SIGTERM,
+	"USR1": syscall.SIGUSR1,
+	"USR2": syscall.SIGUSR2,
+}
+
+// signalByName returns the signal for a name such as "HUP" or "USR1".
+func signalByName(name string) (os.Signal, error) {
+	if sig, found := signalNames[name]; found {
+		return sig, nil
+	}
+	return nil, fmt.Errorf("Unknown signal %q", name)
+}
+
+// waitForSignal blocks until one of stopSignals is received. If i implements
+// Reloader, the reload signal calls Reload and keeps waiting.
+func (c *Config) waitForSignal(i Interface, s Service, stopSignals ...os.Signal) {
+	var sigChan = make(chan os.Signal, 3)
+	signal.Notify(sigChan, stopSignals...)
+	defer signal.Stop(sigChan)
+
+	reloader, isReloader := i.(Reloader)
+	var reloadSig os.Signal
+	if isReloader {
+		var err error
+		if reloadSig, err = signalByName(c.reloadSignalName(i)); err == nil {
+			signal.Notify(sigChan, reloadSig)
+		}
+	}
+
+	for sig := range sigChan {
+		if reloadSig == nil || sig != reloadSig {
+			return
+		}
+		if err := reloader.Reload(s); err != nil {
+			if l, lerr := s.Logger(nil); lerr == nil {
+				l.Error(err)
+			}
+		}
+	}
+}
+
+// signalReload sends the reload signal of the program to the process pid.
+func (c *Config) signalReload(i Interface, pid int) error {
+	name := c.reloadSignalName(i)
+	if len(name) == 0 {
+		return errNoReloadSignal
+	}
+	sig, err := signalByName(name)
+	if err != nil {
+		return err
+	}
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return err
+	}
+	return p.Signal(sig)
+}
+
+// apply writes the files and symlinks of the manifest under the InstallRoot
+// and then runs its commands, stopping at the first error. If InstallRoot is
+// set the commands are not run. It returns the manifest of what was done,
+// with paths under the root.
+//
+// Files are written to a temporary file renamed over the old one. If a step
+// fails the steps done before are undone in reverse order: files and links
+// are restored or removed and enable commands are reverted. The error of
+// the step is returned, in a *RollbackError if undoing failed as well.
+func (c *Config) apply(m *Manifest) (*Manifest, error) {
+	return c.applyManifest(m, false)
+}
+
+// applyManifest implements apply. If the service was installed before, the
+// commands registering it are not reverted, it was registered already, but
+// the ones unregistering it are restored to the state before they ran.
+func (c *Config) applyManifest(m *Manifest, installed bool) (done *Manifest, err error) {
+	root := c.installRoot()
+	done = &Manifest{Commands: m.Commands}
+	var undo []func() error
+	defer func() {
+		if err != nil {
+			done = nil
+			err = rollback(err, undo)
+		}
+	}()
+
+	for _, f := range m.Files {
+		f.Path = filepath.Join(root, f.Path)
+		if err = os.MkdirAll(filepath.Dir(f.Path), 0755); err != nil {
+			return
+		}
+		restore := restoreFile(f.Path)
+		if err = writeFileAtomic(f.Path, f.Content, f.Mode); err != nil {
+			return
+		}
+		undo = append(undo, restore)
+		done.Files = append(done.Files, f)
+	}
+	for _, l := range m.Symlinks {
+		// Targets are left as they are, to resolve on the target system.
+		l.Path = filepath.Join(root, l.Path)
+		if target, lerr := os.Readlink(l.Path); lerr != nil || target != l.Target {
+			restore := restoreSymlink(l.Path)
+			if lerr == nil {
+				// Replace a link to another target.
+				if err = os.Remove(l.Path); err != nil {
+					return
+				}
+			}
+			if err = os.MkdirAll(filepath.Dir(l.Path), 0755); err != nil {
+				return
+			}
+			undo = append(undo, restore)
+			if err = os.Symlink(l.Target, l.Path); err != nil {
+				return
+			}
+		}
+		done.Symlinks = append(done.Symlinks, l)
+	}
+	if len(root) > 0 {
+		// Deferred to the target system.
+		return done, nil
+	}
+	for _, cmd := range m.Commands {
+		cmd := cmd
+		revert, ok := undoCommand(cmd)
+		if installed {
+			revert, ok = restoreCommand(cmd)
+		}
+		if err = c.run(cmd.Name, cmd.Args...); err != nil {
+			return
+		}
+		if isReloadCommand(cmd) {
+			// Reload again once the files are restored.
+			undo = append([]func() error{func() error { return c.run(cmd.Name, cmd.Args...) }}, undo...)
+		} else if ok {
+			undo = append(undo, func() error { return c.run(revert.Name, revert.Args...) })
+		}
+	}
+	return done, nil
+}
 
-	// Connect pipe to read Stderr
-	stderr, err := cmd.StderrPipe()
+// RollbackError is returned when installing fails and the steps done before
+// could not all be undone.
+type RollbackError struct {
+	Err      error   // Error of the failed step.
+	Rollback []error // Errors undoing the steps done before.
+}
+
+func (e *RollbackError) Error() string {
+	msgs := make([]string, len(e.Rollback))
+	for i, err := range e.Rollback {
+		msgs[i] = err.Error()
+	}
+	return fmt.Sprintf("%v Rollback failed: %s", e.Err, strings.Join(msgs, " "))
+}
+
+// rollback runs the undo functions in reverse order and returns err, in a
+// *RollbackError if any of them failed.
+func rollback(err error, undo []func() error) error {
+	var errs []error
+	for i := len(undo) - 1; i >= 0; i-- {
+		if uerr := undo[i](); uerr != nil {
+			errs = append(errs, uerr)
+		}
+	}
+	if len(errs) == 0 {
+		return err
+	}
+	return &RollbackError{Err: err, Rollback: errs}
+}
 
+// restoreFile returns a function restoring the file at path to its current
+// content, or removing it if there is none.
+func restoreFile(path string) func() error {
+	fi, err := os.Stat(path)
 	if err != nil {
-		// Failed to connect pipe
-		return fmt.Errorf("%q failed to connect stderr pipe: %v", command, err)
+		return func() error { return os.Remove(path) }
 	}
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return func() error { return err }
+	}
+	return func() error { return writeFileAtomic(path, content, fi.Mode().Perm()) }
+}
 
-	// Do not use cmd.Run()
-	if err := cmd.Start(); err != nil {
-		// Problem while copying stdin, stdout, or stderr
-		return fmt.Errorf("%q failed: %v", command, err)
+// restoreSymlink returns a function restoring the symlink at path to its
+// current target, or removing it if there is none.
+func restoreSymlink(path string) func() error {
+	target, err := os.Readlink(path)
+	return func() error {
+		if rerr := os.Remove(path); rerr != nil && !os.IsNotExist(rerr) {
+			return rerr
+		}
+		if err != nil {
+			return nil
+		}
+		return os.Symlink(target, path)
 	}
+}
+
+// isReloadCommand reports if cmd makes the init system read its files again.
+func isReloadCommand(cmd Command) bool {
+	return cmd.Name == "systemctl" && len(cmd.Args) > 0 && cmd.Args[len(cmd.Args)-1] == "daemon-reload"
+}
 
-	// Zero exit status
-	// Darwin: launchctl can fail with a zero exit status,
-	// so check for emtpy stderr
-	if command == "launchctl" {
-		slurp, _ := ioutil.ReadAll(stderr)
-		if len(slurp) > 0 {
-			return fmt.Errorf("%q failed with stderr: %s", command, slurp)
+// undoCommand returns the command reverting cmd, if there is one. Enabling
+// lingering is not reverted, it may have been enabled before.
+func undoCommand(cmd Command) (Command, bool) {
+	switch filepath.Base(cmd.Name) {
+	case "update-rc.d":
+		if len(cmd.Args) > 1 && cmd.Args[1] == "defaults" {
+			return Command{Name: cmd.Name, Args: []string{"-f", cmd.Args[0], "remove"}}, true
 		}
+		return Command{}, false
+	case "chkconfig":
+		if len(cmd.Args) == 2 && cmd.Args[0] == "--add" {
+			return Command{Name: cmd.Name, Args: []string{"--del", cmd.Args[1]}}, true
+		}
+		return Command{}, false
+	case "insserv":
+		if len(cmd.Args) == 1 {
+			return Command{Name: cmd.Name, Args: []string{"-r", cmd.Args[0]}}, true
+		}
+		return Command{}, false
+	case "rc-update":
+		if len(cmd.Args) > 0 && cmd.Args[0] == "add" {
+			return Command{Name: cmd.Name, Args: append([]string{"del"}, cmd.Args[1:]...)}, true
+		}
+		return Command{}, false
 	}
+	for i, arg := range cmd.Args {
+		if arg == "enable" {
+			args := append([]string(nil), cmd.Args...)
+			args[i] = "disable"
+			return Command{Name: cmd.Name, Args: args}, true
+		}
+	}
+	return Command{}, false
+}
 
-	if err := cmd.Wait(); err != nil {
-		// Command didn't exit with a zero exit status.
-		return fmt.Errorf("%q failed: %v", command, err)
+// restoreCommand returns the command restoring what cmd undoes on an
+// installed service, read before cmd runs: update-rc.d removes the runlevel
+// links before registering the script again with new priorities.
+func restoreCommand(cmd Command) (Command, bool) {
+	if filepath.Base(cmd.Name) != "update-rc.d" || len(cmd.Args) != 3 || cmd.Args[0] != "-f" || cmd.Args[2] != "remove" {
+		return Command{}, false
 	}
+	name := cmd.Args[1]
+	start, found := rcPriority("/etc/rc2.d/S", name)
+	if !found {
+		return Command{}, false
+	}
+	stop, found := rcPriority("/etc/rc0.d/K", name)
+	if !found {
+		return Command{}, false
+	}
+	return Command{Name: cmd.Name, Args: []string{name, "defaults", fmt.Sprintf("%02d", start), fmt.Sprintf("%02d", stop)}}, true
+}
 
-	return nil
+// update writes the files and symlinks of m that differ from the ones under
+// the InstallRoot and, if any did, runs the commands of m to reload the init
+// system. It reports if the service was installed before and changed.
+func (c *Config) update(m *Manifest) (bool, error) {
+	root := c.installRoot()
+	installed := false
+	changed := &Manifest{Commands: m.Commands}
+	for i, f := range m.Files {
+		path := filepath.Join(root, f.Path)
+		fi, err := os.Stat(path)
+		if err == nil && i == 0 {
+			installed = true
+		}
+		if err == nil && fi.Mode().Perm() == f.Mode.Perm() {
+			if content, err := ioutil.ReadFile(path); err == nil && bytes.Equal(content, f.Content) {
+				continue
+			}
+		}
+		changed.Files = append(changed.Files, f)
+	}
+	for _, l := range m.Symlinks {
+		if target, err := os.Readlink(filepath.Join(root, l.Path)); err == nil && target == l.Target {
+			continue
+		}
+		changed.Symlinks = append(changed.Symlinks, l)
+	}
+	if len(changed.Files) == 0 && len(changed.Symlinks) == 0 {
+		return false, nil
+	}
+	if _, err := c.applyManifest(changed, installed); err != nil {
+		return false, err
+	}
+	return installed, nil
 }
 
-func runWithOutput(command string, arguments ...string) ([]byte, error) {
-	cmd := exec.Command(command, arguments...)
-	return cmd.CombinedOutput()
+// updateService implements Service.Update: the installed files of s are
+// rewritten if they differ from what Install would write.
+func updateService(s updater) error {
+	if err := s.Validate(system); err != nil {
+		return err
+	}
+	m, err := s.Render()
+	if err != nil {
+		return err
+	}
+	if len(m.Files) > 0 {
+		if _, err := os.Stat(filepath.Join(s.installRoot(), m.Files[0].Path)); os.IsNotExist(err) {
+			return ErrServiceIsNotInstalled
+		}
+	}
+	_, err = s.update(m)
+	return err
 }
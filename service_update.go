@@ -0,0 +1,75 @@
+// Copyright 2015 Daniel Theophanes.
+// Use of this source code is governed by a zlib-style
+// license that can be found in the LICENSE file.
+
+package service
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// updater is implemented by the services that can compare what Install
+// would write with what is installed.
+type updater interface {
+	Renderer
+	Validate(system System) error
+	installRoot() string
+	update(m *Manifest) (bool, error)
+}
+
+// InstallOrUpdate installs s if it is not installed. Otherwise it rewrites
+// only the installed files that differ from what Install would write, and
+// then reloads the init system. It reports if s is running and must be
+// restarted to use the new files; nothing is restarted. Calling it again
+// with the same Config changes nothing. Services on Linux and OS X support
+// InstallOrUpdate, others return ErrNoManifest.
+func InstallOrUpdate(s Service) (restart bool, err error) {
+	u, ok := s.(updater)
+	if !ok {
+		return false, ErrNoManifest
+	}
+	if err := u.Validate(system); err != nil {
+		return false, err
+	}
+	m, err := u.Render()
+	if err != nil {
+		return false, err
+	}
+	changed, err := u.update(m)
+	if err != nil || !changed || len(u.installRoot()) > 0 {
+		return false, err
+	}
+	status, err := s.Status()
+	if err != nil {
+		return false, err
+	}
+	return status.State == StateRunning, nil
+}
+
+// writeFileAtomic writes a file next to path and renames it over path, so
+// path has either the old or the new content even if writing fails.
+func writeFileAtomic(path string, content []byte, mode os.FileMode) error {
+	f, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(content); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Chmod(mode); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
@@ -0,0 +1,181 @@
+// Copyright 2015 Daniel Theophanes.
+// Use of this source code is governed by a zlib-style
+// license that can be found in the LICENSE file.
+
+package service
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+type upstart struct {
+	i Interface
+	*Config
+
+	// offline renders for another host, so the local init is not probed.
+	offline bool
+}
+
+// Upstart has some support for user services in graphical sessions.
+// Due to the mix of actual support for user services over versions, just don't bother.
+// Upstart will be replaced by systemd in most cases anyway.
+var errNoUserServiceUpstart = errors.New("User services are not supported on Upstart.")
+
+func (s *upstart) configPath() (cp string, err error) {
+	if s.Option.bool(optionUserService, optionUserServiceDefault) {
+		err = errNoUserServiceUpstart
+		return
+	}
+	cp = "/etc/init/" + s.Config.Name + ".conf"
+	return
+}
+
+func (s *upstart) hasKillStanza() bool {
+	defaultValue := true
+	if s.offline {
+		return defaultValue
+	}
+
+	out, err := s.runWithOutput("/sbin/init", "--version")
+	if err != nil {
+		return defaultValue
+	}
+
+	re := regexp.MustCompile(`init \(upstart (\d+.\d+.\d+)\)`)
+	matches := re.FindStringSubmatch(string(out))
+	if len(matches) != 2 {
+		return defaultValue
+	}
+
+	version := make([]int, 3)
+	for idx, vStr := range strings.Split(matches[1], ".") {
+		version[idx], err = strconv.Atoi(vStr)
+		if err != nil {
+			return defaultValue
+		}
+	}
+
+	maxVersion := []int{0, 6, 5}
+	if versionAtMost(version, maxVersion) {
+		return false
+	}
+
+	return defaultValue
+}
+
+func versionAtMost(version, max []int) bool {
+	for idx, m := range max {
+		v := version[idx]
+		if v > m {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *upstart) template() *template.Template {
+	return template.Must(template.New("").Funcs(tf).Parse(upstartScript))
+}
+
+// Render returns the job configuration written by Install.
+func (s *upstart) Render() (*Manifest, error) {
+	confPath, err := s.configPath()
+	if err != nil {
+		return nil, err
+	}
+	path, err := s.execPath()
+	if err != nil {
+		return nil, err
+	}
+
+	// Upstart can only wait for the start of jobs it knows about, and would
+	// wait forever for a soft dependency that never starts.
+	var jobs []string
+	for _, name := range s.dependencyNames(dependRequires) {
+		if job := jobName(name); len(job) > 0 {
+			jobs = append(jobs, job)
+		}
+	}
+
+	restart := s.restartPolicyOr(RestartPolicy{
+		Mode:   RestartAlways,
+		Burst:  10,
+		Window: 5 * time.Second,
+	})
+
+	var to = &struct {
+		*Config
+		Path          string
+		HasKillStanza bool
+		StopTimeout   int
+		ReloadSignal  string
+
+		Restart      RestartMode
+		RestartDelay int
+		RespawnLimit string
+		NormalExit   string
+
+		Jobs []string
+	}{
+		s.Config,
+		path,
+		s.hasKillStanza(),
+		s.timeoutSeconds(optionStopTimeout, optionStopTimeoutDefault),
+		s.reloadSignalName(s.i),
+
+		restart.Mode,
+		seconds(restart.Delay),
+		fmt.Sprintf("%d %d", restart.Burst, seconds(restart.Window)),
+		joinInts(append([]int{0}, restart.SuccessExitCodes...), " "),
+
+		jobs,
+	}
+
+	var buf bytes.Buffer
+	if err := s.template().Execute(&buf, to); err != nil {
+		return nil, err
+	}
+	return &Manifest{
+		Files: []ManifestFile{{Path: confPath, Mode: 0644, Content: buf.Bytes()}},
+	}, nil
+}
+
+// The upstart script should stop with an INT or the Go runtime will terminate
+// the program before the Stop handler can run.
+const upstartScript = `# {{.Description}}
+
+{{if .DisplayName}}description    "{{.DisplayName}}"{{end}}
+
+{{if .HasKillStanza}}kill signal INT{{end}}
+{{if .StopTimeout}}kill timeout {{.StopTimeout}}{{end}}
+{{if and .ReloadSignal (ne .ReloadSignal "HUP")}}reload signal SIG{{.ReloadSignal}}{{end}}
+{{if .ChRoot}}chroot {{.ChRoot}}{{end}}
+{{if .WorkingDirectory}}chdir {{.WorkingDirectory}}{{end}}
+start on {{if .Jobs}}(filesystem or runlevel [2345]){{range .Jobs}} and started {{.}}{{end}}{{else}}filesystem or runlevel [2345]{{end}}
+stop on {{range .Jobs}}stopping {{.}} or {{end}}runlevel [!2345]
+
+{{if .UserName}}setuid {{.UserName}}{{end}}
+{{range $k, $v := .Envs}}env {{$k}}={{$v | dquote}}
+{{end}}
+{{if ne .Restart "never"}}respawn
+respawn limit {{.RespawnLimit}}{{end}}
+{{if eq .Restart "on-failure"}}normal exit {{.NormalExit}}{{end}}
+{{if and (ne .Restart "never") .RestartDelay}}post-stop exec sleep {{.RestartDelay}}{{end}}
+umask 022
+
+console none
+
+pre-start script
+    test -x {{.Path}} || { stop; exit 0; }
+end script
+
+# Start
+exec {{.Path}}{{range .Arguments}} {{.|cmd}}{{end}}
+`
@@ -5,15 +5,11 @@
 package service
 
 import (
-	"errors"
 	"fmt"
 	"os"
-	"os/exec"
-	"os/signal"
+	"path/filepath"
 	"regexp"
 	"strconv"
-	"strings"
-	"text/template"
 	"time"
 )
 
@@ -27,12 +23,10 @@ func isUpstart() bool {
 	return false
 }
 
-type upstart struct {
-	i Interface
-	*Config
-}
-
 func newUpstartService(i Interface, c *Config) (Service, error) {
+	if c.scheduled() {
+		return newCronService(i, c, upstartName)
+	}
 	s := &upstart{
 		i:      i,
 		Config: c,
@@ -48,96 +42,39 @@ func (s *upstart) String() string {
 	return s.Name
 }
 
-// Upstart has some support for user services in graphical sessions.
-// Due to the mix of actual support for user services over versions, just don't bother.
-// Upstart will be replaced by systemd in most cases anyway.
-var errNoUserServiceUpstart = errors.New("User services are not supported on Upstart.")
-
-func (s *upstart) configPath() (cp string, err error) {
-	if s.Option.bool(optionUserService, optionUserServiceDefault) {
-		err = errNoUserServiceUpstart
-		return
+func validateUpstartConfig(c *Config) []error {
+	var errs []error
+	if c.Option.bool(optionUserService, optionUserServiceDefault) {
+		errs = append(errs, errNoUserServiceUpstart)
 	}
-	cp = "/etc/init/" + s.Config.Name + ".conf"
-	return
+	errs = append(errs, validateCronSchedule(c)...)
+	return errs
 }
 
-func (s *upstart) hasKillStanza() bool {
-	defaultValue := true
-
-	out, err := exec.Command("/sbin/init", "--version").Output()
-	if err != nil {
-		return defaultValue
-	}
-
-	re := regexp.MustCompile(`init \(upstart (\d+.\d+.\d+)\)`)
-	matches := re.FindStringSubmatch(string(out))
-	if len(matches) != 2 {
-		return defaultValue
-	}
-
-	version := make([]int, 3)
-	for idx, vStr := range strings.Split(matches[1], ".") {
-		version[idx], err = strconv.Atoi(vStr)
-		if err != nil {
-			return defaultValue
-		}
-	}
-
-	maxVersion := []int{0, 6, 5}
-	if versionAtMost(version, maxVersion) {
-		return false
-	}
-
-	return defaultValue
+func (s *upstart) Install() error {
+	_, err := s.install()
+	return err
 }
 
-func versionAtMost(version, max []int) bool {
-	for idx, m := range max {
-		v := version[idx]
-		if v > m {
-			return false
-		}
+func (s *upstart) install() (*Manifest, error) {
+	if err := s.Validate(system); err != nil {
+		return nil, err
 	}
-	return true
-}
-
-func (s *upstart) template() *template.Template {
-	return template.Must(template.New("").Funcs(tf).Parse(upstartScript))
-}
-
-func (s *upstart) Install() error {
 	confPath, err := s.configPath()
 	if err != nil {
-		return err
+		return nil, err
 	}
-	_, err = os.Stat(confPath)
+	root := s.installRoot()
+	_, err = os.Stat(filepath.Join(root, confPath))
 	if err == nil {
-		return fmt.Errorf("Init already exists: %s", confPath)
-	}
-
-	f, err := os.Create(confPath)
-	if err != nil {
-		return err
+		return nil, fmt.Errorf("Init already exists: %s", confPath)
 	}
-	defer f.Close()
 
-	path, err := s.execPath()
+	m, err := s.Render()
 	if err != nil {
-		return err
-	}
-
-	var to = &struct {
-		*Config
-		Path          string
-		HasKillStanza bool
-	}{
-		s.Config,
-		path,
-		s.hasKillStanza(),
+		return nil, err
 	}
-
-	return s.template().Execute(f, to)
+	return s.apply(m)
 }
 
 func (s *upstart) Uninstall() error {
@@ -162,26 +99,24 @@ func (s *upstart) SystemLogger(errs chan<- error) (Logger, error) {
 }
 
 func (s *upstart) Run() (err error) {
-	err = s.i.Start(s)
+	err = s.startProgram(s.i, s)
 	if err != nil {
 		return err
 	}
 
 	s.Option.funcSingle(optionRunWait, func() {
-		var sigChan = make(chan os.Signal, 3)
-		signal.Notify(sigChan, os.Interrupt, os.Kill)
-		<-sigChan
+		s.waitForSignal(s.i, s, os.Interrupt, os.Kill)
 	})()
 
-	return s.i.Stop(s)
+	return s.stopProgram(s.i, s)
 }
 
 func (s *upstart) Start() error {
-	return run("initctl", "start", s.Name)
+	return s.run("initctl", "start", s.Name)
 }
 
 func (s *upstart) Stop() error {
-	return run("initctl", "stop", s.Name)
+	return s.run("initctl", "stop", s.Name)
 }
 
 func (s *upstart) Restart() error {
@@ -193,13 +128,24 @@ func (s *upstart) Restart() error {
 	return s.Start()
 }
 
+// Reload sends the ReloadSignal to the job, the job file tells initctl
+// which signal that is.
+func (s *upstart) Reload() error {
+	if len(s.reloadSignalName(s.i)) == 0 {
+		return errNoReloadSignal
+	}
+	return s.run("initctl", "reload", s.Name)
+}
+
+// Update rewrites the installed files that differ from what Install would
+// write. See InstallOrUpdate.
 func (s *upstart) Update() error {
-	return nil
+	return updateService(s)
 }
 
 // Check service is running
 func (s *upstart) checkRunning() (int, error) {
-	output, err := exec.Command("status", s.Name).Output()
+	output, err := s.runWithOutput("status", s.Name)
 	if err != nil {
 		return -1, err
 	}
@@ -218,39 +164,39 @@ func (s *upstart) PID() (int, error) {
 	return s.checkRunning()
 }
 
+var upstartStatusRe = regexp.MustCompile(`(start|stop)/([a-z-]+)(?:, process ([0-9]+))?`)
+
 // Status - Get service status
-func (s *upstart) Status() (string, error) {
-	pid, err := s.checkRunning()
+func (s *upstart) Status() (ServiceStatus, error) {
+	status := ServiceStatus{Backend: upstartName}
+	cp, err := s.configPath()
 	if err != nil {
-		return "", err
+		return status, err
+	}
+	if _, err := os.Stat(cp); os.IsNotExist(err) {
+		status.State = StateNotInstalled
+		return status, nil
 	}
-	return "(pid: " + strconv.Itoa(pid) + ")", nil
-}
-
-// The upstart script should stop with an INT or the Go runtime will terminate
-// the program before the Stop handler can run.
-const upstartScript = `# {{.Description}}
-
-{{if .DisplayName}}description    "{{.DisplayName}}"{{end}}
-
-{{if .HasKillStanza}}kill signal INT{{end}}
-{{if .ChRoot}}chroot {{.ChRoot}}{{end}}
-{{if .WorkingDirectory}}chdir {{.WorkingDirectory}}{{end}}
-start on filesystem or runlevel [2345]
-stop on runlevel [!2345]
-
-{{if .UserName}}setuid {{.UserName}}{{end}}
-
-respawn
-respawn limit 10 5
-umask 022
-
-console none
-
-pre-start script
-    test -x {{.Path}} || { stop; exit 0; }
-end script
 
-# Start
-exec {{.Path}}{{range .Arguments}} {{.|cmd}}{{end}}
-`
+	output, err := s.runWithOutput("status", s.Name)
+	if err != nil {
+		if _, ok := exitStatus(err); !ok {
+			return status, err
+		}
+	}
+	data := upstartStatusRe.FindStringSubmatch(string(output))
+	switch {
+	case data == nil:
+		status.State = StateUnknown
+	case data[1] == "stop":
+		status.State = StateStopped
+	case data[2] == "running":
+		status.State = StateRunning
+	default:
+		status.State = StateActivating
+	}
+	if len(data) > 3 && data[3] != "" {
+		status.PID, _ = strconv.Atoi(data[3])
+	}
+	return status, nil
+}
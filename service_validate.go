@@ -0,0 +1,158 @@
+// Copyright 2015 Daniel Theophanes.
+// Use of this source code is governed by a zlib-style
+// license that can be found in the LICENSE file.
+
+package service
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ValidationError is returned by Config.Validate and lists every problem
+// found in the Config.
+type ValidationError struct {
+	Errors []error
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		msgs[i] = err.Error()
+	}
+	return "Invalid service config: " + strings.Join(msgs, " ")
+}
+
+var envNameRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// optionTypes holds the Go type expected for each known Config.Option.
+var optionTypes = map[string]reflect.Type{
+	optionKeepAlive:         reflect.TypeOf(optionKeepAliveDefault),
+	optionRunAtLoad:         reflect.TypeOf(optionRunAtLoadDefault),
+	optionUserService:       reflect.TypeOf(optionUserServiceDefault),
+	optionSessionCreate:     reflect.TypeOf(optionSessionCreateDefault),
+	optionStandardOutPath:   reflect.TypeOf(""),
+	optionStandardErrorPath: reflect.TypeOf(""),
+	optionRunWait:           reflect.TypeOf(func() {}),
+	optionReloadSignal:      reflect.TypeOf(""),
+	optionPIDFile:           reflect.TypeOf(""),
+	optionStartTimeout:      reflect.TypeOf(time.Duration(0)),
+	optionStopTimeout:       reflect.TypeOf(time.Duration(0)),
+	optionExitOnTimeout:     reflect.TypeOf(optionExitOnTimeoutDefault),
+	optionPassword:          reflect.TypeOf(""),
+	optionInstallRoot:       reflect.TypeOf(""),
+	optionNotify:            reflect.TypeOf(optionNotifyDefault),
+	optionWatchdogSec:       reflect.TypeOf(time.Duration(0)),
+	optionLinger:            reflect.TypeOf(optionLingerDefault),
+	optionListenStream:      reflect.TypeOf([]string(nil)),
+	optionListenDatagram:    reflect.TypeOf([]string(nil)),
+}
+
+// configValidator is implemented by a System that does not support every
+// Config field. validateConfig returns one error per unsupported setting.
+type configValidator interface {
+	validateConfig(c *Config) []error
+}
+
+// errUnsupported reports a Config field the named system would ignore.
+func errUnsupported(field, system string) error {
+	return fmt.Errorf("%s is not supported on %s.", field, system)
+}
+
+// Validate checks the Config for problems that would produce a broken
+// service on the given system, which defaults to the chosen system if nil.
+// All problems are reported at once in a *ValidationError.
+// Install and Update call Validate before changing anything.
+func (c *Config) Validate(system System) error {
+	if system == nil {
+		system = ChosenSystem()
+	}
+	var errs []error
+
+	if len(c.Name) == 0 {
+		errs = append(errs, ErrNameFieldRequired)
+	} else if strings.ContainsAny(c.Name, " \t\r\n/\\") {
+		errs = append(errs, fmt.Errorf("Config.Name %q must not contain spaces or slashes.", c.Name))
+	}
+
+	// When staging under an InstallRoot the executable and user belong to the
+	// target system and may not exist on this one.
+	staging := len(c.installRoot()) > 0
+
+	if fields := strings.Fields(c.Executable); len(fields) > 0 && !staging {
+		if _, err := exec.LookPath(fields[0]); err != nil {
+			errs = append(errs, fmt.Errorf("Config.Executable %q is not an executable file.", fields[0]))
+		}
+	}
+
+	// Windows account names are resolved by the service manager.
+	if len(c.UserName) > 0 && runtime.GOOS != "windows" && !staging {
+		name := c.UserName
+		if pos := strings.Index(name, ":"); pos != -1 {
+			name = name[:pos]
+		}
+		if _, err := user.Lookup(name); err != nil {
+			errs = append(errs, fmt.Errorf("Config.UserName %q does not exist.", name))
+		}
+	}
+
+	for name := range c.Envs {
+		if !envNameRe.MatchString(name) {
+			errs = append(errs, fmt.Errorf("Config.Envs name %q is not a valid environment variable name.", name))
+		}
+	}
+
+	errs = append(errs, c.validateDependencies()...)
+
+	switch c.RestartPolicy.Mode {
+	case "", RestartAlways, RestartOnFailure, RestartNever:
+	default:
+		errs = append(errs, fmt.Errorf("Config.RestartPolicy.Mode %q must be one of %s, %s or %s.", c.RestartPolicy.Mode, RestartAlways, RestartOnFailure, RestartNever))
+	}
+	if c.RestartPolicy.Delay < 0 || c.RestartPolicy.Burst < 0 || c.RestartPolicy.Window < 0 {
+		errs = append(errs, errors.New("Config.RestartPolicy values must not be negative."))
+	}
+
+	if d, ok := c.scheduleInterval(); ok && d < time.Second {
+		errs = append(errs, fmt.Errorf("Config.Schedule %q must be at least one second.", c.Schedule))
+	} else if strings.ContainsAny(c.Schedule, "\r\n") {
+		errs = append(errs, fmt.Errorf("Config.Schedule %q must be a single line.", c.Schedule))
+	}
+
+	if len(c.WorkingDirectory) > 0 && !filepath.IsAbs(c.WorkingDirectory) {
+		errs = append(errs, fmt.Errorf("Config.WorkingDirectory %q must be an absolute path.", c.WorkingDirectory))
+	}
+
+	names := make([]string, 0, len(c.Option))
+	for name := range c.Option {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		want, known := optionTypes[name]
+		if !known {
+			continue
+		}
+		if got := reflect.TypeOf(c.Option[name]); got != want {
+			errs = append(errs, fmt.Errorf("Option %s must be of type %v, not %v.", name, want, got))
+		}
+	}
+
+	if v, ok := system.(configValidator); ok {
+		errs = append(errs, v.validateConfig(c)...)
+	}
+
+	if len(errs) > 0 {
+		return &ValidationError{Errors: errs}
+	}
+	return nil
+}
@@ -0,0 +1,38 @@
+// Copyright 2015 Daniel Theophanes.
+// Use of this source code is governed by a zlib-style
+// license that can be found in the LICENSE file.
+
+package service
+
+import (
+	"testing"
+	"time"
+)
+
+func TestValidate(t *testing.T) {
+	c := &Config{
+		Name:             "go service/test",
+		Executable:       "/nonexistent/go_service_test",
+		WorkingDirectory: "relative/dir",
+		Option: KeyValue{
+			optionKeepAlive:    "yes",
+			optionStopTimeout:  5,
+			optionStartTimeout: 5 * time.Second,
+			"Unknown":          1,
+		},
+	}
+	err := c.Validate(ChosenSystem())
+	verr, ok := err.(*ValidationError)
+	if !ok {
+		t.Fatalf("Validate err: %v, want *ValidationError", err)
+	}
+	// Name, Executable, WorkingDirectory, KeepAlive and StopTimeout.
+	if len(verr.Errors) < 5 {
+		t.Errorf("Validate found %d problems, want at least 5: %v", len(verr.Errors), err)
+	}
+
+	c = &Config{Name: "go_service_test"}
+	if err := c.Validate(ChosenSystem()); err != nil {
+		t.Errorf("Validate of minimal config: %v", err)
+	}
+}
@@ -48,6 +48,21 @@ func (windowsSystem) Detect() bool {
 func (windowsSystem) Interactive() bool {
 	return interactive
 }
+func (windowsSystem) validateConfig(c *Config) []error {
+	var errs []error
+	// WorkingDirectory is ignored rather than rejected, configs shared with
+	// the other systems often set it.
+	if len(c.ChRoot) != 0 {
+		errs = append(errs, errUnsupported("Config.ChRoot", version))
+	}
+	if len(c.RestartPolicy.Mode) != 0 || c.RestartPolicy.Delay != 0 || c.RestartPolicy.Burst != 0 || c.RestartPolicy.Window != 0 || len(c.RestartPolicy.SuccessExitCodes) != 0 {
+		errs = append(errs, errUnsupported("Config.RestartPolicy", version))
+	}
+	if len(c.Schedule) != 0 {
+		errs = append(errs, errUnsupported("Config.Schedule", version))
+	}
+	return errs
+}
 func (windowsSystem) New(i Interface, c *Config) (Service, error) {
 	ws := &windowsService{
 		i:      i,
@@ -159,10 +174,14 @@ func (ws *windowsService) getError() error {
 }
 
 func (ws *windowsService) Execute(args []string, r <-chan svc.ChangeRequest, changes chan<- svc.Status) (bool, uint32) {
-	const cmdsAccepted = svc.AcceptStop | svc.AcceptShutdown
+	cmdsAccepted := svc.AcceptStop | svc.AcceptShutdown
+	reloader, isReloader := ws.i.(Reloader)
+	if isReloader {
+		cmdsAccepted |= svc.AcceptParamChange
+	}
 	changes <- svc.Status{State: svc.StartPending}
 
-	if err := ws.i.Start(ws); err != nil {
+	if err := ws.startProgram(ws.i, ws); err != nil {
 		ws.setError(err)
 		return true, 1
 	}
@@ -174,9 +193,18 @@ loop:
 		switch c.Cmd {
 		case svc.Interrogate:
 			changes <- c.CurrentStatus
+		case svc.ParamChange:
+			if isReloader {
+				if err := reloader.Reload(ws); err != nil {
+					if l, lerr := ws.Logger(nil); lerr == nil {
+						l.Error(err)
+					}
+				}
+			}
+			changes <- c.CurrentStatus
 		case svc.Stop, svc.Shutdown:
 			changes <- svc.Status{State: svc.StopPending}
-			if err := ws.i.Stop(ws); err != nil {
+			if err := ws.stopProgram(ws.i, ws); err != nil {
 				ws.setError(err)
 				return true, 2
 			}
@@ -190,6 +218,9 @@ loop:
 }
 
 func (ws *windowsService) Install() error {
+	if err := ws.Validate(system); err != nil {
+		return err
+	}
 	exepath, err := ws.execPath()
 	if err != nil {
 		return err
@@ -210,8 +241,8 @@ func (ws *windowsService) Install() error {
 		Description:      ws.Description,
 		StartType:        mgr.StartAutomatic,
 		ServiceStartName: ws.UserName,
-		Password:         ws.Option.string("Password", ""),
-		Dependencies:     ws.Dependencies,
+		Password:         ws.Option.string(optionPassword, ""),
+		Dependencies:     ws.dependencyEntries(dependRequires),
 	}, ws.Arguments...)
 	if err != nil {
 		return err
@@ -248,6 +279,9 @@ func (ws *windowsService) Uninstall() error {
 }
 
 func (ws *windowsService) Update() error {
+	if err := ws.Validate(system); err != nil {
+		return err
+	}
 	// no need to recreate service
 	return nil
 }
@@ -269,7 +303,7 @@ func (ws *windowsService) Run() error {
 		}
 		return nil
 	}
-	err := ws.i.Start(ws)
+	err := ws.startProgram(ws.i, ws)
 	if err != nil {
 		return err
 	}
@@ -280,7 +314,7 @@ func (ws *windowsService) Run() error {
 
 	<-sigChan
 
-	return ws.i.Stop(ws)
+	return ws.stopProgram(ws.i, ws)
 }
 
 func (ws *windowsService) Start() error {
@@ -335,6 +369,25 @@ func (ws *windowsService) Restart() error {
 	return s.Start()
 }
 
+// Reload sends the ParamChange control, which calls Reloader.Reload in the
+// running service.
+func (ws *windowsService) Reload() error {
+	m, err := mgr.Connect()
+	if err != nil {
+		return err
+	}
+	defer m.Disconnect()
+
+	s, err := m.OpenService(ws.Name)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	_, err = s.Control(svc.ParamChange)
+	return err
+}
+
 func (ws *windowsService) stopWait(s *mgr.Service) error {
 	// First stop the service. Then wait for the service to
 	// actually stop before starting it.
@@ -451,10 +504,43 @@ func (ws *windowsService) PID() (int, error) {
 	return ws.checkRunning()
 }
 
-func (ws *windowsService) Status() (string, error) {
-	pid, err := ws.checkRunning()
+func (ws *windowsService) Status() (ServiceStatus, error) {
+	status := ServiceStatus{Backend: version}
+	m, err := mgr.Connect()
 	if err != nil {
-		return "", err
+		return status, err
+	}
+	defer m.Disconnect()
+
+	s, err := m.OpenService(ws.Name)
+	if err == windows.ERROR_SERVICE_DOES_NOT_EXIST {
+		status.State = StateNotInstalled
+		return status, nil
+	}
+	if err != nil {
+		return status, err
+	}
+	defer s.Close()
+
+	st, err := s.Query()
+	if err != nil {
+		return status, err
+	}
+	status.PID = int(st.ProcessId)
+	status.ExitCode = int(st.Win32ExitCode)
+	switch st.State {
+	case svc.StartPending:
+		status.State = StateActivating
+	case svc.Running, svc.StopPending, svc.Paused, svc.PausePending, svc.ContinuePending:
+		status.State = StateRunning
+	case svc.Stopped:
+		if st.Win32ExitCode != 0 {
+			status.State = StateFailed
+		} else {
+			status.State = StateStopped
+		}
+	default:
+		status.State = StateUnknown
 	}
-	return "running (pid: " + strconv.Itoa(pid) + ")", nil
+	return status, nil
 }
@@ -0,0 +1,121 @@
+// Copyright 2015 Daniel Theophanes.
+// Use of this source code is governed by a zlib-style
+// license that can be found in the LICENSE file.
+
+// Package servicetest provides fakes to test code using package service
+// without a service system or greater rights. System runs programs in
+// memory and lets a test play the OS service manager; Commander fakes the
+// commands of a real service system to test its backend.
+package servicetest // import "github.com/isaaxiot/service/servicetest"
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/isaaxiot/service"
+)
+
+// Commander is a service.Commander that records the commands it is asked to
+// run and answers them with canned responses. Commands without a response
+// succeed with no output. It is safe for concurrent use.
+//
+// Set it as the Config.Commander of a service to test a service system
+// backend:
+//
+//	cmd := &servicetest.Commander{}
+//	cmd.Respond("systemctl show -p ActiveState,MainPID app.service", "ActiveState=inactive\nMainPID=0\n", 0)
+//	s, err := service.New(prog, &service.Config{Name: "app", Commander: cmd})
+type Commander struct {
+	mu        sync.Mutex
+	calls     []service.Command
+	responses map[string]response
+}
+
+type response struct {
+	output []byte
+	code   int
+}
+
+// ExitError is returned for a command that exits with a non-zero status.
+type ExitError struct {
+	Command string
+	Code    int
+}
+
+func (e *ExitError) Error() string {
+	return fmt.Sprintf("%q exited with status %d", e.Command, e.Code)
+}
+
+// ExitCode returns the exit status of the command.
+func (e *ExitError) ExitCode() int {
+	return e.Code
+}
+
+// Respond sets the output and exit status of a command line, the name and
+// arguments joined by single spaces. A non-zero code makes the command fail
+// with an *ExitError.
+func (c *Commander) Respond(command, output string, code int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.responses == nil {
+		c.responses = make(map[string]response)
+	}
+	c.responses[command] = response{output: []byte(output), code: code}
+}
+
+// Run records the command and fails if its response has a non-zero code.
+func (c *Commander) Run(name string, args ...string) error {
+	_, err := c.Output(name, args...)
+	return err
+}
+
+// Output records the command and returns its response.
+func (c *Commander) Output(name string, args ...string) ([]byte, error) {
+	cmd := service.Command{Name: name, Args: args}
+	line := cmd.String()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calls = append(c.calls, cmd)
+	r := c.responses[line]
+	if r.code != 0 {
+		return r.output, &ExitError{Command: line, Code: r.code}
+	}
+	return r.output, nil
+}
+
+// Calls returns the commands run so far, in order.
+func (c *Commander) Calls() []service.Command {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]service.Command(nil), c.calls...)
+}
+
+// Lines returns the command lines run so far, in order.
+func (c *Commander) Lines() []string {
+	calls := c.Calls()
+	lines := make([]string, len(calls))
+	for i, cmd := range calls {
+		lines[i] = cmd.String()
+	}
+	return lines
+}
+
+// Reset forgets the commands run so far but keeps the responses.
+func (c *Commander) Reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calls = nil
+}
+
+// HasRun reports if the command line has been run.
+func (c *Commander) HasRun(command string) bool {
+	for _, line := range c.Lines() {
+		if line == command {
+			return true
+		}
+	}
+	return false
+}
+
+var _ service.Commander = &Commander{}
@@ -0,0 +1,407 @@
+// Copyright 2015 Daniel Theophanes.
+// Use of this source code is governed by a zlib-style
+// license that can be found in the LICENSE file.
+
+package servicetest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/isaaxiot/service"
+)
+
+// Name is the name of the fake service system, as returned by
+// System.String and reported in ServiceStatus.Backend.
+const Name = "servicetest"
+
+// Events recorded by Service, in the order they happen.
+const (
+	EventInstall   = "Install"
+	EventUninstall = "Uninstall"
+	EventUpdate    = "Update"
+	EventStart     = "Start"
+	EventStop      = "Stop"
+	EventRestart   = "Restart"
+	EventReload    = "Reload"
+
+	// Calls to the program made by Run.
+	EventProgramStart  = "Interface.Start"
+	EventProgramStop   = "Interface.Stop"
+	EventProgramReload = "Reloader.Reload"
+)
+
+// ErrNotRunning is returned when the OS service manager is simulated while
+// Run is not waiting for it.
+var ErrNotRunning = errors.New("Service.Run is not running.")
+
+// System is a service.System that keeps its services in memory. Register it
+// with service.OverrideSystem so service.New returns its services:
+//
+//	sys := servicetest.NewSystem()
+//	service.OverrideSystem(sys)
+//	s, _ := service.New(prog, config)
+//	go s.Run()
+//	// Wait until sys.Service(config.Name).Waiting() is true.
+//	sys.Service(config.Name).SignalStop()
+type System struct {
+	mu          sync.Mutex
+	interactive bool
+	services    map[string]*Service
+}
+
+// NewSystem returns a System whose services run as if started by the OS
+// service manager.
+func NewSystem() *System {
+	return &System{services: make(map[string]*Service)}
+}
+
+func (sys *System) String() string {
+	return Name
+}
+
+// Detect always returns true.
+func (sys *System) Detect() bool {
+	return true
+}
+
+// Interactive returns the value set by SetInteractive, false by default.
+func (sys *System) Interactive() bool {
+	sys.mu.Lock()
+	defer sys.mu.Unlock()
+	return sys.interactive
+}
+
+// SetInteractive sets what Interactive returns.
+func (sys *System) SetInteractive(interactive bool) {
+	sys.mu.Lock()
+	defer sys.mu.Unlock()
+	sys.interactive = interactive
+}
+
+// New returns a new *Service. A later call with the same Config.Name
+// replaces the service returned by Service.
+func (sys *System) New(i service.Interface, c *service.Config) (service.Service, error) {
+	if len(c.Name) == 0 {
+		return nil, service.ErrNameFieldRequired
+	}
+	s := &Service{
+		sys:      sys,
+		i:        i,
+		Config:   c,
+		requests: make(chan request),
+	}
+	sys.mu.Lock()
+	defer sys.mu.Unlock()
+	sys.services[c.Name] = s
+	return s, nil
+}
+
+// Service returns the last service created with the given name, or nil.
+func (sys *System) Service(name string) *Service {
+	sys.mu.Lock()
+	defer sys.mu.Unlock()
+	return sys.services[name]
+}
+
+// request is an OS service manager request handled by Run.
+type request struct {
+	reload bool
+	done   chan error
+}
+
+// Service is a service.Service of System. Besides the service.Service
+// methods it lets a test act as the OS service manager while Run is
+// running and inspect what happened.
+//
+// The control methods such as Start and Install only change the state
+// reported by Status, they do not run the program.
+type Service struct {
+	*service.Config
+
+	sys      *System
+	i        service.Interface
+	requests chan request
+	// signalMu serializes the requests, so Run still waits for a request
+	// once signal saw it waiting.
+	signalMu sync.Mutex
+
+	mu        sync.Mutex
+	waiting   bool
+	installed bool
+	state     service.State
+	events    []string
+	logs      []string
+}
+
+var _ service.Service = &Service{}
+
+func (s *Service) record(event string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = append(s.events, event)
+}
+
+func (s *Service) setState(state service.State) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.state = state
+}
+
+// Events returns the control calls and program calls so far, in order,
+// for example EventInstall, EventProgramStart, EventProgramStop.
+func (s *Service) Events() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.events...)
+}
+
+// Logs returns the lines logged through the Logger of the service so far,
+// prefixed with "I: ", "W: " or "E: " like service.ConsoleLogger.
+func (s *Service) Logs() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.logs...)
+}
+
+// Waiting reports if Run is waiting for SignalReload and SignalStop, from
+// when the program Start returned until SignalStop.
+func (s *Service) Waiting() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.waiting
+}
+
+// Installed reports if the service is installed.
+func (s *Service) Installed() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.installed
+}
+
+// Run calls the program Start, then waits for SignalReload and SignalStop
+// and calls the program Reload and Stop. It returns once Stop returns.
+// ContextInterface programs get a context without deadline.
+func (s *Service) Run() error {
+	s.record(EventProgramStart)
+	var err error
+	if ci, ok := s.i.(service.ContextInterface); ok {
+		err = ci.StartContext(context.Background(), s)
+	} else {
+		err = s.i.Start(s)
+	}
+	if err != nil {
+		s.setState(service.StateFailed)
+		return err
+	}
+	s.mu.Lock()
+	s.state = service.StateRunning
+	s.waiting = true
+	s.mu.Unlock()
+
+	for req := range s.requests {
+		if req.reload {
+			s.record(EventProgramReload)
+			if r, ok := s.i.(service.Reloader); ok {
+				req.done <- r.Reload(s)
+			} else {
+				req.done <- errors.New("Program does not implement service.Reloader.")
+			}
+			continue
+		}
+
+		s.mu.Lock()
+		s.waiting = false
+		s.events = append(s.events, EventProgramStop)
+		s.mu.Unlock()
+		if ci, ok := s.i.(service.ContextInterface); ok {
+			err = ci.StopContext(context.Background(), s)
+		} else {
+			err = s.i.Stop(s)
+		}
+		if err != nil {
+			s.setState(service.StateFailed)
+		} else {
+			s.setState(service.StateStopped)
+		}
+		req.done <- err
+		return err
+	}
+	return nil
+}
+
+// signal hands a request to Run, and returns the result of handling it or
+// ErrNotRunning if Run is not waiting for requests.
+func (s *Service) signal(reload bool) error {
+	s.signalMu.Lock()
+	defer s.signalMu.Unlock()
+	s.mu.Lock()
+	waiting := s.waiting
+	s.mu.Unlock()
+	if !waiting {
+		return ErrNotRunning
+	}
+	req := request{reload: reload, done: make(chan error, 1)}
+	s.requests <- req
+	return <-req.done
+}
+
+// SignalStop acts as the OS service manager asking Run to stop. It blocks
+// until the program Stop returned, and returns the error of Stop. It returns
+// ErrNotRunning unless Run is waiting, see Waiting.
+func (s *Service) SignalStop() error {
+	return s.signal(false)
+}
+
+// SignalReload acts as the OS service manager asking Run to reload. It
+// blocks until the program Reload returned, and returns the error of
+// Reload. It returns ErrNotRunning unless Run is waiting, see SignalStop.
+func (s *Service) SignalReload() error {
+	return s.signal(true)
+}
+
+func (s *Service) control(event string, state service.State) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.installed {
+		return service.ErrServiceIsNotInstalled
+	}
+	s.events = append(s.events, event)
+	s.state = state
+	return nil
+}
+
+// Start marks the installed service as running.
+func (s *Service) Start() error {
+	return s.control(EventStart, service.StateRunning)
+}
+
+// Stop marks the installed service as stopped.
+func (s *Service) Stop() error {
+	return s.control(EventStop, service.StateStopped)
+}
+
+// Restart marks the installed service as running.
+func (s *Service) Restart() error {
+	return s.control(EventRestart, service.StateRunning)
+}
+
+// Reload records the reload of the installed service. Use SignalReload to
+// have Run reload the program.
+func (s *Service) Reload() error {
+	s.mu.Lock()
+	state := s.state
+	s.mu.Unlock()
+	return s.control(EventReload, state)
+}
+
+// Install validates the Config and marks the service as installed.
+func (s *Service) Install() error {
+	if err := s.Validate(s.sys); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.installed {
+		return fmt.Errorf("Init already exists: %s", s.Name)
+	}
+	s.events = append(s.events, EventInstall)
+	s.installed = true
+	s.state = service.StateStopped
+	return nil
+}
+
+// Uninstall marks the service as not installed.
+func (s *Service) Uninstall() error {
+	if err := s.control(EventUninstall, service.StateNotInstalled); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.installed = false
+	return nil
+}
+
+// Update validates the Config of the installed service.
+func (s *Service) Update() error {
+	if err := s.Validate(s.sys); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	state := s.state
+	s.mu.Unlock()
+	return s.control(EventUpdate, state)
+}
+
+// Status returns the state set by the control methods and Run.
+func (s *Service) Status() (service.ServiceStatus, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	status := service.ServiceStatus{Backend: Name, State: s.state}
+	if !s.installed && s.state != service.StateRunning {
+		status.State = service.StateNotInstalled
+	}
+	if status.State == service.StateRunning {
+		status.PID = os.Getpid()
+	}
+	return status, nil
+}
+
+// PID returns the PID of the test process if the service is running.
+func (s *Service) PID() (int, error) {
+	status, _ := s.Status()
+	if status.State != service.StateRunning {
+		return -1, service.ErrServiceIsNotRunning
+	}
+	return status.PID, nil
+}
+
+func (s *Service) String() string {
+	if len(s.DisplayName) > 0 {
+		return s.DisplayName
+	}
+	return s.Name
+}
+
+// Logger returns a logger that records to Logs.
+func (s *Service) Logger(errs chan<- error) (service.Logger, error) {
+	return logger{s}, nil
+}
+
+// SystemLogger returns a logger that records to Logs.
+func (s *Service) SystemLogger(errs chan<- error) (service.Logger, error) {
+	return logger{s}, nil
+}
+
+type logger struct {
+	s *Service
+}
+
+func (l logger) log(prefix, msg string) error {
+	l.s.mu.Lock()
+	defer l.s.mu.Unlock()
+	l.s.logs = append(l.s.logs, prefix+msg)
+	return nil
+}
+
+func (l logger) Error(v ...interface{}) error {
+	return l.log("E: ", fmt.Sprint(v...))
+}
+func (l logger) Warning(v ...interface{}) error {
+	return l.log("W: ", fmt.Sprint(v...))
+}
+func (l logger) Info(v ...interface{}) error {
+	return l.log("I: ", fmt.Sprint(v...))
+}
+func (l logger) Errorf(format string, a ...interface{}) error {
+	return l.log("E: ", fmt.Sprintf(format, a...))
+}
+func (l logger) Warningf(format string, a ...interface{}) error {
+	return l.log("W: ", fmt.Sprintf(format, a...))
+}
+func (l logger) Infof(format string, a ...interface{}) error {
+	return l.log("I: ", fmt.Sprintf(format, a...))
+}
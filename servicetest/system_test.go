@@ -0,0 +1,108 @@
+// Copyright 2015 Daniel Theophanes.
+// Use of this source code is governed by a zlib-style
+// license that can be found in the LICENSE file.
+
+package servicetest_test
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/isaaxiot/service"
+	"github.com/isaaxiot/service/servicetest"
+)
+
+type program struct{}
+
+func (p *program) Start(s service.Service) error {
+	l, _ := s.Logger(nil)
+	return l.Info("started")
+}
+func (p *program) Stop(s service.Service) error {
+	l, _ := s.Logger(nil)
+	return l.Info("stopped")
+}
+func (p *program) Reload(s service.Service) error {
+	l, _ := s.Logger(nil)
+	return l.Warning("reloaded")
+}
+
+func TestSystem(t *testing.T) {
+	sys := servicetest.NewSystem()
+	service.OverrideSystem(sys)
+	if service.Platform() != servicetest.Name || service.Interactive() {
+		t.Fatalf("Platform %s, Interactive %v", service.Platform(), service.Interactive())
+	}
+
+	s, err := service.New(&program{}, &service.Config{Name: "go_service_test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fake := sys.Service("go_service_test")
+
+	if err := s.Start(); err != service.ErrServiceIsNotInstalled {
+		t.Errorf("Start before Install err: %v", err)
+	}
+	if err := s.Install(); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Install(); err == nil {
+		t.Error("second Install should fail")
+	}
+
+	if err := fake.SignalStop(); err != servicetest.ErrNotRunning {
+		t.Errorf("SignalStop before Run err: %v", err)
+	}
+	done := make(chan error)
+	go func() {
+		done <- s.Run()
+	}()
+	waitRunning(t, fake)
+	if err := fake.SignalReload(); err != nil {
+		t.Fatal(err)
+	}
+	if status, _ := s.Status(); status.State != service.StateRunning {
+		t.Errorf("Status while running: %v", status)
+	}
+	if err := fake.SignalStop(); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if err := fake.SignalReload(); err != servicetest.ErrNotRunning {
+		t.Errorf("SignalReload after Run err: %v", err)
+	}
+	if err := s.Uninstall(); err != nil {
+		t.Fatal(err)
+	}
+	if status, _ := s.Status(); status.State != service.StateNotInstalled {
+		t.Errorf("Status after Uninstall: %v", status)
+	}
+
+	events := []string{
+		servicetest.EventInstall,
+		servicetest.EventProgramStart,
+		servicetest.EventProgramReload,
+		servicetest.EventProgramStop,
+		servicetest.EventUninstall,
+	}
+	if got := fake.Events(); !reflect.DeepEqual(got, events) {
+		t.Errorf("Events: %q, want %q", got, events)
+	}
+	logs := []string{"I: started", "W: reloaded", "I: stopped"}
+	if got := fake.Logs(); !reflect.DeepEqual(got, logs) {
+		t.Errorf("Logs: %q, want %q", got, logs)
+	}
+}
+
+// waitRunning waits for Run to wait for requests.
+func waitRunning(t *testing.T, s *servicetest.Service) {
+	for i := 0; !s.Waiting(); i++ {
+		if i == 100 {
+			t.Fatal("Run is not waiting")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}